@@ -0,0 +1,523 @@
+package filebacked
+
+import (
+	liberr "github.com/konveyor/controller/pkg/error"
+	"reflect"
+	"sort"
+)
+
+//
+// Filter the objects.
+// The objects for which the function returns true are
+// included. The result cannot be derived by index so
+// matched objects are (lazily) spooled to a list.
+// Corrupt source objects are not counted; the errors are
+// reported by TryNext() in (source) order.
+func Filter(itr Iterator, fn func(object interface{}) bool) Iterator {
+	spool := &spool{
+		source: itr,
+		match:  fn,
+	}
+	return &adaptor{
+		length:  spool.Len,
+		has:     spool.has,
+		at:      spool.at,
+		pending: spool.pending,
+		close:   spool.close,
+	}
+}
+
+//
+// Map (transform) the objects.
+func Map(itr Iterator, fn func(object interface{}) interface{}) Iterator {
+	return &adaptor{
+		length: itr.Len,
+		has: func(index int) bool {
+			return has(itr, index)
+		},
+		at: func(index int) (object interface{}, err error) {
+			object, err = tryAt(itr, index)
+			if err == nil {
+				object = fn(object)
+			}
+			return
+		},
+		close: itr.Close,
+	}
+}
+
+//
+// Concatenate the iterators.
+func Concat(itrs ...Iterator) Iterator {
+	return &adaptor{
+		length: func() (n int) {
+			for _, itr := range itrs {
+				n += itr.Len()
+			}
+			return
+		},
+		has: func(index int) bool {
+			for _, itr := range itrs {
+				if has(itr, index) {
+					return true
+				}
+				index -= count(itr, index)
+			}
+			return false
+		},
+		at: func(index int) (object interface{}, err error) {
+			for _, itr := range itrs {
+				if has(itr, index) {
+					object, err = tryAt(itr, index)
+					return
+				}
+				index -= count(itr, index)
+			}
+			err = liberr.New("index out of range.")
+			return
+		},
+		close: func() {
+			for _, itr := range itrs {
+				itr.Close()
+			}
+		},
+	}
+}
+
+//
+// Batch the objects.
+// Each object is a slice ([]interface{}) of (up to) n
+// objects.
+func Batch(itr Iterator, n int) Iterator {
+	if n < 1 {
+		n = 1
+	}
+	return &adaptor{
+		length: func() int {
+			return (itr.Len() + n - 1) / n
+		},
+		has: func(index int) bool {
+			return has(itr, index*n)
+		},
+		at: func(index int) (object interface{}, err error) {
+			batch := []interface{}{}
+			for i := index * n; i < (index+1)*n; i++ {
+				if !has(itr, i) {
+					break
+				}
+				var next interface{}
+				next, err = tryAt(itr, i)
+				if err != nil {
+					return
+				}
+				batch = append(batch, next)
+			}
+			object = batch
+			return
+		},
+		close: itr.Close,
+	}
+}
+
+//
+// Take (up to) the first n objects.
+func Take(itr Iterator, n int) Iterator {
+	if n < 0 {
+		n = 0
+	}
+	return &adaptor{
+		length: func() int {
+			length := itr.Len()
+			if length > n {
+				length = n
+			}
+			return length
+		},
+		has: func(index int) bool {
+			return index < n && has(itr, index)
+		},
+		at: func(index int) (interface{}, error) {
+			return tryAt(itr, index)
+		},
+		close: itr.Close,
+	}
+}
+
+//
+// Skip the first n objects.
+func Skip(itr Iterator, n int) Iterator {
+	if n < 0 {
+		n = 0
+	}
+	return &adaptor{
+		length: func() int {
+			length := itr.Len() - n
+			if length < 0 {
+				length = 0
+			}
+			return length
+		},
+		has: func(index int) bool {
+			return has(itr, index+n)
+		},
+		at: func(index int) (interface{}, error) {
+			return tryAt(itr, index+n)
+		},
+		close: itr.Close,
+	}
+}
+
+//
+// Zip the iterators.
+// Each object is a slice ([]interface{}) containing the
+// object at the same index in each iterator. The length
+// is the length of the shortest iterator.
+func Zip(itrs ...Iterator) Iterator {
+	return &adaptor{
+		length: func() (n int) {
+			for i, itr := range itrs {
+				if i == 0 || itr.Len() < n {
+					n = itr.Len()
+				}
+			}
+			return
+		},
+		has: func(index int) bool {
+			for _, itr := range itrs {
+				if !has(itr, index) {
+					return false
+				}
+			}
+			return len(itrs) > 0
+		},
+		at: func(index int) (object interface{}, err error) {
+			tuple := []interface{}{}
+			for _, itr := range itrs {
+				var next interface{}
+				next, err = tryAt(itr, index)
+				if err != nil {
+					return
+				}
+				tuple = append(tuple, next)
+			}
+			object = tuple
+			return
+		},
+		close: func() {
+			for _, itr := range itrs {
+				itr.Close()
+			}
+		},
+	}
+}
+
+//
+// Iterator adaptor.
+// Objects are derived by index from the source.
+type adaptor struct {
+	// Length.
+	length func() int
+	// Has object at (source) index.
+	// Used for (lazy) iteration without the length.
+	has func(index int) bool
+	// Object at (source) index.
+	at func(index int) (interface{}, error)
+	// Pending error (optional) reported by TryNext()
+	// before the object at (source) index.
+	pending func(index int) error
+	// Close the source.
+	close func()
+	// Reversed.
+	reversed bool
+	// Current position.
+	current int
+}
+
+//
+// Number of items.
+func (r *adaptor) Len() int {
+	return r.length()
+}
+
+//
+// Reverse.
+func (r *adaptor) Reverse() {
+	r.reversed = !r.reversed
+}
+
+//
+// Object at index.
+func (r *adaptor) At(index int) (object interface{}) {
+	object, err := r.TryAt(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+//
+// Object at index (with).
+func (r *adaptor) AtWith(index int, object interface{}) {
+	err := r.TryAtWith(index, object)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//
+// Next object.
+func (r *adaptor) Next() (object interface{}, hasNext bool) {
+	object, hasNext, err := r.TryNext()
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+//
+// Next object (with).
+func (r *adaptor) NextWith(object interface{}) (hasNext bool) {
+	hasNext, err := r.TryNextWith(object)
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+//
+// Object at index.
+func (r *adaptor) TryAt(index int) (object interface{}, err error) {
+	if r.reversed {
+		index = r.Len() - 1 - index
+	}
+	object, err = r.at(index)
+	return
+}
+
+//
+// Object at index (with).
+func (r *adaptor) TryAtWith(index int, object interface{}) (err error) {
+	value, err := r.TryAt(index)
+	if err != nil {
+		return
+	}
+	err = assign(object, value)
+	return
+}
+
+//
+// Next object.
+func (r *adaptor) TryNext() (object interface{}, hasNext bool, err error) {
+	if r.hasNext() {
+		hasNext = true
+		err = r.pendingErr()
+		if err != nil {
+			return
+		}
+		object, err = r.TryAt(r.current)
+		r.current++
+	}
+
+	return
+}
+
+//
+// Next object (with).
+func (r *adaptor) TryNextWith(object interface{}) (hasNext bool, err error) {
+	if r.hasNext() {
+		hasNext = true
+		err = r.pendingErr()
+		if err != nil {
+			return
+		}
+		err = r.TryAtWith(r.current, object)
+		r.current++
+	}
+
+	return
+}
+
+//
+// Close the iterator.
+func (r *adaptor) Close() {
+	r.close()
+}
+
+//
+// Has next object.
+func (r *adaptor) hasNext() bool {
+	if r.reversed {
+		return r.current < r.Len()
+	}
+
+	return r.has(r.current)
+}
+
+//
+// Pending error at the current position.
+// The position is not advanced.
+func (r *adaptor) pendingErr() (err error) {
+	if r.pending != nil && !r.reversed {
+		err = r.pending(r.current)
+	}
+
+	return
+}
+
+//
+// Spooled (filtered) objects.
+type spool struct {
+	// Source.
+	source Iterator
+	// Match function.
+	match func(object interface{}) bool
+	// Matched objects.
+	list *List
+	// Corrupt source objects (errors) by the index
+	// of the matched object that follows.
+	errs map[int][]error
+	// Source exhausted.
+	done bool
+}
+
+//
+// Number of (matched) objects.
+// The source is fully spooled.
+func (r *spool) Len() int {
+	r.fill(-1)
+	return r.list.Len()
+}
+
+//
+// Has object (or pending error) at index.
+func (r *spool) has(index int) bool {
+	r.fill(index)
+	return index < r.list.Len() || len(r.errs[index]) > 0
+}
+
+//
+// Object at index.
+func (r *spool) at(index int) (object interface{}, err error) {
+	r.fill(index)
+	if index < 0 || index >= r.list.Len() {
+		err = liberr.New("index out of range.")
+		return
+	}
+	object, err = r.list.TryAt(index)
+	return
+}
+
+//
+// Next pending error at index.
+// Each error is reported once.
+func (r *spool) pending(index int) (err error) {
+	errs := r.errs[index]
+	if len(errs) > 0 {
+		err = errs[0]
+		r.errs[index] = errs[1:]
+	}
+
+	return
+}
+
+//
+// Spool source objects until the object at index is
+// matched or the source is exhausted. A negative index
+// spools all objects. Corrupt source objects cannot be
+// matched so the errors are kept (by the index of the
+// next matched object) separately from the list.
+func (r *spool) fill(index int) {
+	if r.list == nil {
+		r.list = NewList()
+	}
+	for !r.done && (index < 0 || index >= r.list.Len()) {
+		object, hasNext, err := tryNext(r.source)
+		if !hasNext {
+			r.done = true
+			break
+		}
+		if err != nil {
+			if r.errs == nil {
+				r.errs = make(map[int][]error)
+			}
+			next := r.list.Len()
+			r.errs[next] = append(r.errs[next], err)
+			continue
+		}
+		if r.match(object) {
+			r.list.Append(object)
+		}
+	}
+}
+
+//
+// Close the source and spool.
+func (r *spool) close() {
+	r.source.Close()
+	if r.list != nil {
+		r.list.Close()
+	}
+}
+
+//
+// Has object at index.
+// Lazy for adaptors.
+func has(itr Iterator, index int) bool {
+	if index < 0 {
+		return false
+	}
+	if r, cast := itr.(*adaptor); cast && !r.reversed {
+		return r.has(index)
+	}
+
+	return index < itr.Len()
+}
+
+//
+// Number of objects (up to limit).
+// Lazy for adaptors.
+func count(itr Iterator, limit int) int {
+	return sort.Search(
+		limit,
+		func(index int) bool {
+			return !has(itr, index)
+		})
+}
+
+//
+// Assign the value to the object (pointer).
+func assign(object, value interface{}) (err error) {
+	ov := reflect.ValueOf(object)
+	if ov.Kind() != reflect.Ptr || ov.IsNil() {
+		err = liberr.New("object must be a pointer.")
+		return
+	}
+	ov = ov.Elem()
+	vv := reflect.ValueOf(value)
+	if !vv.IsValid() {
+		ov.Set(reflect.Zero(ov.Type()))
+		return
+	}
+	if vv.Type().AssignableTo(ov.Type()) {
+		ov.Set(vv)
+		return
+	}
+	if vv.Kind() == reflect.Ptr && !vv.IsNil() {
+		if vv.Elem().Type().AssignableTo(ov.Type()) {
+			ov.Set(vv.Elem())
+			return
+		}
+	}
+
+	err = liberr.New(
+		"value not assignable to object.",
+		"value",
+		vv.Type().String(),
+		"object",
+		ov.Type().String())
+
+	return
+}
@@ -1,6 +1,7 @@
 package filebacked
 
 import (
+	liberr "github.com/konveyor/controller/pkg/error"
 	"reflect"
 	"sync"
 )
@@ -9,16 +10,63 @@ import (
 // Catalog (singleton).
 var catalog = Catalog{}
 
+//
+// Type registry (singleton).
+var registry = Registry{
+	codecs: map[string]Codec{
+		GobCodec.Name():    GobCodec,
+		JsonCodec.Name():   JsonCodec,
+		BinaryCodec.Name(): BinaryCodec,
+	},
+}
+
+//
+// Register a type by name.
+// Types must be registered to be written to (and read
+// from) named lists. The name must be stable across
+// processes. Panics when the name is registered to a
+// different type or the type is registered by a different name.
+func Register(name string, object interface{}) {
+	registry.add(name, object)
+}
+
+//
+// Register a codec by name.
+// Custom codecs must be registered to be used by
+// named lists.
+func RegisterCodec(codec Codec) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.codecs[codec.Name()] = codec
+}
+
+//
+// The max number of kinds in the catalog.
+// The high bit of the (entry) kind is the compressed flag.
+const MaxKinds = int(compressed)
+
 //
 // Type catalog.
+// The kind table. Each kind is a type and the
+// codec used to encode (write) the entry.
 type Catalog struct {
 	sync.Mutex
-	content []interface{}
+	content []catalogEntry
+}
+
+//
+// Catalog (kind) entry.
+type catalogEntry struct {
+	// Object (proto).
+	proto interface{}
+	// Codec.
+	codec Codec
 }
 
 //
 // Add object (proto) to the catalog.
-func (r *Catalog) add(object interface{}) (kind uint16) {
+// Returns CatalogFull when MaxKinds has been reached.
+func (r *Catalog) add(object interface{}, codec Codec) (kind uint16, err error) {
 	if object == nil {
 		return
 	}
@@ -31,31 +79,160 @@ func (r *Catalog) add(object interface{}) (kind uint16) {
 		ov = ov.Elem()
 	}
 	// Found.
+	// Codecs are compared by name because
+	// the codec may not be comparable.
 	for k, f := range r.content {
-		if ot == reflect.TypeOf(f) {
+		if ot == reflect.TypeOf(f.proto) && codec.Name() == f.codec.Name() {
 			kind = uint16(k)
 			return
 		}
 	}
 	// Added.
+	if len(r.content) >= MaxKinds {
+		err = liberr.Wrap(CatalogFull, "limit", MaxKinds)
+		return
+	}
 	kind = uint16(len(r.content))
-	r.content = append(r.content, ov.Interface())
+	r.content = append(
+		r.content,
+		catalogEntry{
+			proto: ov.Interface(),
+			codec: codec,
+		})
 
 	return
 }
 
 //
 // Build object using the catalog.
-func (r *Catalog) build(kind uint16) (object interface{}, found bool) {
+func (r *Catalog) build(kind uint16) (object interface{}, codec Codec, found bool) {
 	r.Lock()
 	defer r.Unlock()
 	content := r.content
 	i := int(kind)
 	if i < len(content) {
-		object = content[i]
-		object = reflect.New(reflect.TypeOf(object)).Interface()
+		entry := content[i]
+		object = reflect.New(reflect.TypeOf(entry.proto)).Interface()
+		codec = entry.codec
 		found = true
 	}
 
 	return
 }
+
+//
+// Codec used to encode the kind.
+func (r *Catalog) codec(kind uint16) (codec Codec, found bool) {
+	r.Lock()
+	defer r.Unlock()
+	i := int(kind)
+	if i < len(r.content) {
+		codec = r.content[i].codec
+		found = true
+	}
+
+	return
+}
+
+//
+// Type registry.
+// Maps stable names to types.
+type Registry struct {
+	sync.Mutex
+	// Types by name.
+	types map[string]reflect.Type
+	// Names by type.
+	names map[reflect.Type]string
+	// Codecs by name.
+	codecs map[string]Codec
+}
+
+//
+// Add a type.
+func (r *Registry) add(name string, object interface{}) {
+	r.Lock()
+	defer r.Unlock()
+	if r.types == nil {
+		r.types = make(map[string]reflect.Type)
+		r.names = make(map[reflect.Type]string)
+	}
+	ot := reflect.TypeOf(object)
+	if ot.Kind() == reflect.Ptr {
+		ot = ot.Elem()
+	}
+	if found, registered := r.types[name]; registered && found != ot {
+		panic(
+			liberr.New(
+				"name already registered.",
+				"name",
+				name,
+				"type",
+				found.String()))
+	}
+	if found, registered := r.names[ot]; registered && found != name {
+		panic(
+			liberr.New(
+				"type already registered.",
+				"type",
+				ot.String(),
+				"name",
+				found))
+	}
+	r.types[name] = ot
+	r.names[ot] = name
+}
+
+//
+// Find the registered name for the object.
+func (r *Registry) name(object interface{}) (name string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	ot := reflect.TypeOf(object)
+	if ot.Kind() == reflect.Ptr {
+		ot = ot.Elem()
+	}
+	name, found := r.names[ot]
+	if !found {
+		err = liberr.New(
+			"type not registered.",
+			"type",
+			ot.String())
+	}
+
+	return
+}
+
+//
+// Build a (proto) object for the registered name.
+func (r *Registry) build(name string) (object interface{}, err error) {
+	r.Lock()
+	defer r.Unlock()
+	ot, found := r.types[name]
+	if !found {
+		err = liberr.New(
+			"type not registered.",
+			"name",
+			name)
+		return
+	}
+
+	object = reflect.New(ot).Interface()
+
+	return
+}
+
+//
+// Find a codec by name.
+func (r *Registry) codec(name string) (codec Codec, err error) {
+	r.Lock()
+	defer r.Unlock()
+	codec, found := r.codecs[name]
+	if !found {
+		err = liberr.New(
+			"codec not registered.",
+			"name",
+			name)
+	}
+
+	return
+}
@@ -0,0 +1,165 @@
+package filebacked
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	liberr "github.com/konveyor/controller/pkg/error"
+)
+
+//
+// Codecs.
+var (
+	// Gob (default).
+	GobCodec Codec = &gobCodec{}
+	// JSON.
+	JsonCodec Codec = &jsonCodec{}
+	// Binary.
+	BinaryCodec Codec = &binaryCodec{}
+)
+
+//
+// Default codec.
+// Used by lists without a codec.
+var DefaultCodec = GobCodec
+
+//
+// Object codec.
+// Encodes objects written to (and decoded from) the file.
+type Codec interface {
+	// Name (unique).
+	Name() string
+	// Encode the object.
+	Encode(object interface{}) ([]byte, error)
+	// Decode into the object (pointer).
+	Decode(b []byte, object interface{}) error
+}
+
+//
+// Gob codec.
+type gobCodec struct{}
+
+//
+// Name.
+func (r *gobCodec) Name() string {
+	return "gob"
+}
+
+//
+// Encode the object.
+func (r *gobCodec) Encode(object interface{}) (b []byte, err error) {
+	var bfr bytes.Buffer
+	encoder := gob.NewEncoder(&bfr)
+	err = encoder.Encode(object)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	b = bfr.Bytes()
+
+	return
+}
+
+//
+// Decode into the object.
+func (r *gobCodec) Decode(b []byte, object interface{}) (err error) {
+	decoder := gob.NewDecoder(bytes.NewBuffer(b))
+	err = decoder.Decode(object)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// JSON codec.
+// Supports types gob cannot encode (for example: no
+// exported fields) and produces human readable files.
+type jsonCodec struct{}
+
+//
+// Name.
+func (r *jsonCodec) Name() string {
+	return "json"
+}
+
+//
+// Encode the object.
+func (r *jsonCodec) Encode(object interface{}) (b []byte, err error) {
+	b, err = json.Marshal(object)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Decode into the object.
+func (r *jsonCodec) Decode(b []byte, object interface{}) (err error) {
+	err = json.Unmarshal(b, object)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Binary codec.
+// Objects implementing encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler are encoded using their own
+// (fast) implementation. Otherwise, the object must be
+// fixed-size data (as defined by encoding/binary) and is
+// encoded little-endian.
+type binaryCodec struct{}
+
+//
+// Name.
+func (r *binaryCodec) Name() string {
+	return "binary"
+}
+
+//
+// Encode the object.
+func (r *binaryCodec) Encode(object interface{}) (b []byte, err error) {
+	if m, cast := object.(encoding.BinaryMarshaler); cast {
+		b, err = m.MarshalBinary()
+		if err != nil {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	var bfr bytes.Buffer
+	err = binary.Write(&bfr, binary.LittleEndian, object)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	b = bfr.Bytes()
+
+	return
+}
+
+//
+// Decode into the object.
+func (r *binaryCodec) Decode(b []byte, object interface{}) (err error) {
+	if m, cast := object.(encoding.BinaryUnmarshaler); cast {
+		err = m.UnmarshalBinary(b)
+		if err != nil {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	err = binary.Read(bytes.NewReader(b), binary.LittleEndian, object)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
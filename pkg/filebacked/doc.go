@@ -1,4 +1,87 @@
 /*
 Provides file-backed collections.
+
+File format:
+   | kind: 2 (uint16)
+   | size: 8 (uint64)
+   | crc: 4 (uint32)
+   | object: n (encoded)
+   | ...
+The codec used to encode the object is recorded
+by the kind (catalog). The high bit of the kind is
+set when the object is (flate) compressed. The CRC32
+(IEEE) covers the kind, size and (stored) object.
+
+//
+// New list.
+list := fb.NewList()
+
+//
+// Append an object.
+list.Append(object)
+
+//
+// Iterate the list.
+itr := list.Iter()
+for i := 0; i < itr.Len(); i++ {
+    person := itr.At(i)
+    ...
+}
+
+//
+// Iterate the list.
+itr := list.Iter()
+for i := 0; i < itr.Len(); i++ {
+    person := Person{}
+    itr.AtWith(i, &person))
+    ...
+}
+
+//
+// Iterate the list.
+itr := list.Iter()
+for {
+    object, hasNext := itr.Next()
+    if !hasNext {
+        break
+    }
+    ...
+}
+
+//
+// Iterate the list.
+itr := list.Iter()
+for object, hasNext := itr.Next(); hasNext; object, hasNext = itr.Next() {
+    ...
+}
+
+//
+// Iterate the list.
+itr := list.Iter()
+for {
+    person := Person{}
+    hasNext := itr.NextWith(&person))
+    if !hasNext {
+        break
+    }
+    ...
+}
+
+//
+// Sort a list.
+sorted := list.Sort(
+    func(a, b interface{}) bool {
+        return a.(*Person).Name < b.(*Person).Name
+    })
+
+//
+// File-backed map.
+mp := fb.NewHashMap()
+mp.Put("elmer", &Person{})
+person, found := mp.Get("elmer")
+
+//
+// Compose iterators.
+itr := fb.Take(fb.Filter(list.Iter(), adult), 10)
 */
 package filebacked
@@ -0,0 +1,46 @@
+package filebacked
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// Errors.
+var (
+	// Entry corrupted.
+	Corrupt = errors.New("corrupt entry")
+	// MaxKinds reached.
+	CatalogFull = errors.New("catalog full")
+)
+
+//
+// Entry corrupted (or truncated).
+// Matches Corrupt using errors.Is().
+type CorruptError struct {
+	// File path.
+	Path string
+	// Entry index.
+	Index int
+	// Entry offset.
+	Offset int64
+	// Reason.
+	Reason string
+}
+
+//
+// Error description.
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf(
+		"%s: entry (index=%d offset=%d) corrupt: %s",
+		e.Path,
+		e.Index,
+		e.Offset,
+		e.Reason)
+}
+
+//
+// Matches Corrupt.
+func (e *CorruptError) Is(target error) bool {
+	return target == Corrupt
+}
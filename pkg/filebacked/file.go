@@ -1,21 +1,18 @@
-/*
-File backing for collections.
-File format:
-   | kind: 2 (uint16)
-   | size: 8 (uint64)
-   | object: n (gob encoded)
-   | ...
-*/
 package filebacked
 
 import (
 	"bytes"
+	"compress/flate"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	liberr "github.com/konveyor/controller/pkg/error"
 	"github.com/konveyor/controller/pkg/logging"
+	"hash/crc32"
 	"io"
+	"io/ioutil"
 	"os"
 	pathlib "path"
 	"runtime"
@@ -26,13 +23,28 @@ var log = logging.WithName("filebacked")
 //
 // File extension.
 const (
-	Extension = ".fb"
+	Extension      = ".fb"
+	IndexExtension = ".idx"
 )
 
 //
 // Working Directory.
 var WorkingDir = "/tmp"
 
+//
+// Default (flate) compression level.
+// Used by new lists. Entries are not compressed when
+// the level is flate.NoCompression.
+var Compression = flate.NoCompression
+
+//
+// Entry kind flag: compressed.
+const compressed = uint16(1 << 15)
+
+//
+// Entry header size.
+const headerSize = 14
+
 //
 // Writer.
 type Writer struct {
@@ -44,29 +56,83 @@ type Writer struct {
 	index []int64
 	// Dirty (needs flush).
 	dirty bool
+	// Codec.
+	codec Codec
+	// Compression (flate) level.
+	compression int
+	// Persistent (named).
+	persistent bool
+	// Kinds (manifest) written to a persistent file.
+	manifest []manifestEntry
+	// Catalog kind => file kind.
+	toFile map[uint16]uint16
+	// File kind => catalog kind.
+	toCatalog map[uint16]uint16
+}
+
+//
+// Persisted index.
+// Written for named lists.
+type indexFile struct {
+	// Data file size.
+	Size int64
+	// Direct access index.
+	Index []int64
+	// Kinds (manifest) indexed by file kind.
+	Kinds []manifestEntry
+}
+
+//
+// Manifest (kind) entry.
+type manifestEntry struct {
+	// Registered type name.
+	Type string
+	// Codec name.
+	Codec string
 }
 
 //
 // Append (write) object.
 func (w *Writer) Append(object interface{}) {
+	err := w.TryAppend(object)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//
+// Append (write) object.
+// Returns CatalogFull when MaxKinds has been reached.
+func (w *Writer) TryAppend(object interface{}) (err error) {
 	// Lazy open.
 	w.open()
 	// Seek end.
-	_, err := w.file.Seek(0, io.SeekEnd)
+	_, err = w.file.Seek(0, io.SeekEnd)
 	if err != nil {
-		panic(err)
+		err = liberr.Wrap(err)
+		return
 	}
 	// Update catalog.
-	kind := catalog.add(object)
+	codec := w.codec
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	kind, err := catalog.add(object, codec)
+	if err != nil {
+		return
+	}
+	kind, err = w.fileKind(kind, object, codec)
+	if err != nil {
+		return
+	}
 	// Encode object.
-	var bfr bytes.Buffer
-	encoder := gob.NewEncoder(&bfr)
-	err = encoder.Encode(object)
+	b, err := codec.Encode(object)
 	if err != nil {
-		panic(err)
+		err = liberr.Wrap(err)
+		return
 	}
 	// Write entry.
-	offset := w.writeEntry(kind, bfr)
+	offset := w.writeEntry(kind, b)
 	w.index = append(w.index, offset)
 	w.dirty = true
 
@@ -75,7 +141,9 @@ func (w *Writer) Append(object interface{}) {
 		"path",
 		w.path,
 		"kind",
-		kind)
+		kind,
+		"codec",
+		codec.Name())
 
 	return
 }
@@ -86,13 +154,16 @@ func (w *Writer) Reader(shared bool) (reader *Reader) {
 	w.open()
 	w.flush()
 	if !shared {
-		path := w.newPath()
+		path := pathlib.Join(
+			pathlib.Dir(w.path),
+			pathlib.Base(w.newPath()))
 		err := os.Link(w.path, path)
 		if err != nil {
 			panic(err)
 		}
 		reader = &Reader{
 			index: w.index[:],
+			kinds: w.readerKinds(),
 			path:  path,
 		}
 		runtime.SetFinalizer(
@@ -103,6 +174,7 @@ func (w *Writer) Reader(shared bool) (reader *Reader) {
 	} else {
 		reader = &Reader{
 			index: w.index[:],
+			kinds: w.readerKinds(),
 			path:  w.path,
 			file:  w.file,
 		}
@@ -119,14 +191,26 @@ func (w *Writer) Reader(shared bool) (reader *Reader) {
 
 //
 // Close the writer.
+// Persistent files are saved rather than deleted.
 func (w *Writer) Close() {
-	defer func() {
-		_ = os.Remove(w.path)
-	}()
-	if w.file == nil {
-		return
+	if w.persistent {
+		if w.file == nil {
+			return
+		}
+		err := w.Save()
+		if err != nil {
+			log.Trace(err)
+		}
+	} else {
+		defer func() {
+			_ = os.Remove(w.path)
+		}()
+		if w.file == nil {
+			return
+		}
 	}
 	_ = w.file.Close()
+	w.file = nil
 	log.V(5).Info(
 		"writer: closed.",
 		"path",
@@ -154,8 +238,10 @@ func (w *Writer) open() {
 		return
 	}
 	var err error
-	w.path = w.newPath()
-	w.file, err = os.Create(w.path)
+	if w.path == "" {
+		w.path = w.newPath()
+	}
+	w.file, err = os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		panic(err)
 	}
@@ -169,34 +255,31 @@ func (w *Writer) open() {
 
 //
 // Write entry.
-func (w *Writer) writeEntry(kind uint16, bfr bytes.Buffer) (offset int64) {
+func (w *Writer) writeEntry(kind uint16, encoded []byte) (offset int64) {
 	file := w.file
 	offset, err := file.Seek(0, io.SeekCurrent)
 	if err != nil {
 		panic(err)
 	}
-	// Write object kind.
-	b := make([]byte, 2)
-	binary.LittleEndian.PutUint16(b, kind)
-	_, err = file.Write(b)
-	if err != nil {
-		panic(err)
-	}
-	// Write object encoded length.
-	n := bfr.Len()
-	b = make([]byte, 8)
-	binary.LittleEndian.PutUint64(b, uint64(n))
+	// Compress.
+	encoded, kind = w.compress(encoded, kind)
+	// Write header: kind, length, checksum.
+	n := len(encoded)
+	b := make([]byte, headerSize)
+	binary.LittleEndian.PutUint16(b[0:2], kind)
+	binary.LittleEndian.PutUint64(b[2:10], uint64(n))
+	binary.LittleEndian.PutUint32(b[10:14], checksum(b[0:10], encoded))
 	_, err = file.Write(b)
 	if err != nil {
 		panic(err)
 	}
 	// Write encoded object.
-	nWrite, err := file.Write(bfr.Bytes())
+	nWrite, err := file.Write(encoded)
 	if err != nil {
 		panic(err)
 	}
 	if n != nWrite {
-		err = liberr.New("Write failed.")
+		panic(liberr.New("Write failed."))
 	}
 	log.V(6).Info(
 		"writer: write entry.",
@@ -210,6 +293,250 @@ func (w *Writer) writeEntry(kind uint16, bfr bytes.Buffer) (offset int64) {
 	return
 }
 
+//
+// Save (sync) the file.
+// For persistent files, the index and kind manifest are
+// saved so the file may be reopened using Load().
+func (w *Writer) Save() (err error) {
+	w.open()
+	err = w.file.Sync()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	w.dirty = false
+	if !w.persistent {
+		return
+	}
+	st, err := w.file.Stat()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	index := indexFile{
+		Size:  st.Size(),
+		Index: w.index,
+		Kinds: w.manifest,
+	}
+	path := w.path + IndexExtension
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	encoder := gob.NewEncoder(file)
+	err = encoder.Encode(&index)
+	if err == nil {
+		err = file.Sync()
+	}
+	_ = file.Close()
+	if err != nil {
+		_ = os.Remove(tmp)
+		err = liberr.Wrap(err)
+		return
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	log.V(5).Info(
+		"writer: saved.",
+		"path",
+		w.path,
+		"length",
+		len(w.index))
+
+	return
+}
+
+//
+// Load the index and kind manifest for the (persistent)
+// file at path. Entries appended after the last Save()
+// are discarded. Types in the manifest must be registered.
+func (w *Writer) Load(path string) (err error) {
+	w.path = path
+	w.persistent = true
+	w.index = nil
+	w.manifest = nil
+	w.toFile = make(map[uint16]uint16)
+	w.toCatalog = make(map[uint16]uint16)
+	index := indexFile{}
+	file, err := os.Open(path + IndexExtension)
+	if err == nil {
+		decoder := gob.NewDecoder(file)
+		err = decoder.Decode(&index)
+		_ = file.Close()
+		if err != nil {
+			err = liberr.Wrap(err, "path", path)
+			return
+		}
+	} else {
+		if !os.IsNotExist(err) {
+			err = liberr.Wrap(err)
+			return
+		}
+		err = nil
+	}
+	for fileKind, entry := range index.Kinds {
+		var proto interface{}
+		var codec Codec
+		proto, err = registry.build(entry.Type)
+		if err != nil {
+			return
+		}
+		codec, err = registry.codec(entry.Codec)
+		if err != nil {
+			return
+		}
+		var kind uint16
+		kind, err = catalog.add(proto, codec)
+		if err != nil {
+			return
+		}
+		w.toFile[kind] = uint16(fileKind)
+		w.toCatalog[uint16(fileKind)] = kind
+	}
+	w.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		_ = w.file.Close()
+		w.file = nil
+		err = liberr.Wrap(err)
+		return
+	}
+	if info.Size() < index.Size {
+		_ = w.file.Close()
+		w.file = nil
+		err = truncated(path, index, info.Size())
+		return
+	}
+	err = w.file.Truncate(index.Size)
+	if err != nil {
+		_ = w.file.Close()
+		w.file = nil
+		err = liberr.Wrap(err)
+		return
+	}
+	w.index = index.Index
+	w.manifest = index.Kinds
+
+	log.V(5).Info(
+		"writer: loaded.",
+		"path",
+		w.path,
+		"length",
+		len(w.index))
+
+	return
+}
+
+//
+// Build the corrupt entry error for a file shorter
+// than the saved index.
+func truncated(path string, index indexFile, size int64) error {
+	err := &CorruptError{
+		Path:   path,
+		Offset: size,
+		Reason: fmt.Sprintf(
+			"file truncated (%d of %d bytes)",
+			size,
+			index.Size),
+	}
+	// First entry not wholly within the file.
+	for i, offset := range index.Index {
+		end := index.Size
+		if i+1 < len(index.Index) {
+			end = index.Index[i+1]
+		}
+		if end > size {
+			err.Index = i
+			err.Offset = offset
+			break
+		}
+	}
+
+	return err
+}
+
+//
+// Map the catalog kind to the file kind.
+// Persistent files record kinds in the manifest by
+// registered type name.
+func (w *Writer) fileKind(kind uint16, object interface{}, codec Codec) (fileKind uint16, err error) {
+	if !w.persistent {
+		fileKind = kind
+		return
+	}
+	fileKind, found := w.toFile[kind]
+	if found {
+		return
+	}
+	name, err := registry.name(object)
+	if err != nil {
+		return
+	}
+	fileKind = uint16(len(w.manifest))
+	w.manifest = append(
+		w.manifest,
+		manifestEntry{
+			Type:  name,
+			Codec: codec.Name(),
+		})
+	w.toFile[kind] = fileKind
+	w.toCatalog[fileKind] = kind
+
+	return
+}
+
+//
+// File kind => catalog kind (copy) for readers.
+func (w *Writer) readerKinds() (kinds map[uint16]uint16) {
+	if !w.persistent {
+		return
+	}
+	kinds = make(map[uint16]uint16)
+	for k, v := range w.toCatalog {
+		kinds[k] = v
+	}
+
+	return
+}
+
+//
+// Compress the encoded object.
+// The object is stored uncompressed when compression is
+// disabled or does not reduce the size.
+func (w *Writer) compress(encoded []byte, kind uint16) ([]byte, uint16) {
+	if w.compression == flate.NoCompression {
+		return encoded, kind
+	}
+	var bfr bytes.Buffer
+	writer, err := flate.NewWriter(&bfr, w.compression)
+	if err != nil {
+		panic(liberr.Wrap(err))
+	}
+	_, err = writer.Write(encoded)
+	if err != nil {
+		panic(liberr.Wrap(err))
+	}
+	err = writer.Close()
+	if err != nil {
+		panic(liberr.Wrap(err))
+	}
+	if bfr.Len() >= len(encoded) {
+		return encoded, kind
+	}
+
+	return bfr.Bytes(), kind | compressed
+}
+
 //
 // New path.
 func (w *Writer) newPath() string {
@@ -227,6 +554,9 @@ type Reader struct {
 	file *os.File
 	// Direct access index.
 	index []int64
+	// File kind => catalog kind.
+	// Nil when the same.
+	kinds map[uint16]uint16
 	// shared
 	shared bool
 }
@@ -241,26 +571,45 @@ func (r *Reader) Len() (length int) {
 //
 // Get the object at index.
 func (r *Reader) At(index int) (object interface{}) {
-	// Lazy open.
-	r.open()
-	// Seek.
-	offset := r.index[index]
-	_, err := r.file.Seek(offset, io.SeekStart)
+	object, err := r.TryAt(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+//
+// Get the object at index.
+func (r *Reader) AtWith(index int, object interface{}) {
+	err := r.TryAtWith(index, object)
 	if err != nil {
 		panic(err)
 	}
+
+	return
+}
+
+//
+// Get the object at index.
+// Returns CorruptError when the entry is corrupt.
+func (r *Reader) TryAt(index int) (object interface{}, err error) {
 	// Read entry.
-	kind, b := r.readEntry()
+	kind, b, err := r.readEntry(index)
+	if err != nil {
+		return
+	}
 	// Decode object.
-	bfr := bytes.NewBuffer(b)
-	decoder := gob.NewDecoder(bfr)
-	object, found := catalog.build(kind)
+	object, codec, found := catalog.build(kind)
 	if !found {
-		panic(liberr.New("object not found in catalog."))
+		err = r.corrupt(index, "kind not found in catalog")
+		return
 	}
-	err = decoder.Decode(object)
+	err = codec.Decode(b, object)
 	if err != nil {
-		panic(err)
+		object = nil
+		err = r.corrupt(index, "decode failed: "+err.Error())
+		return
 	}
 
 	log.V(6).Info(
@@ -275,23 +624,23 @@ func (r *Reader) At(index int) (object interface{}) {
 
 //
 // Get the object at index.
-func (r *Reader) AtWith(index int, object interface{}) {
-	// Lazy open.
-	r.open()
-	// Seek.
-	offset := r.index[index]
-	_, err := r.file.Seek(offset, io.SeekStart)
+// Returns CorruptError when the entry is corrupt.
+func (r *Reader) TryAtWith(index int, object interface{}) (err error) {
+	// Read entry.
+	kind, b, err := r.readEntry(index)
 	if err != nil {
-		panic(err)
+		return
 	}
-	// Read entry.
-	_, b := r.readEntry()
 	// Decode object.
-	bfr := bytes.NewBuffer(b)
-	decoder := gob.NewDecoder(bfr)
-	err = decoder.Decode(object)
+	codec, found := catalog.codec(kind)
+	if !found {
+		err = r.corrupt(index, "kind not found in catalog")
+		return
+	}
+	err = codec.Decode(b, object)
 	if err != nil {
-		panic(err)
+		err = r.corrupt(index, "decode failed: "+err.Error())
+		return
 	}
 
 	log.V(6).Info(
@@ -324,44 +673,65 @@ func (r *Reader) Close() {
 }
 
 //
-// Read next entry.
-func (r *Reader) readEntry() (kind uint16, bfr []byte) {
-	file := r.file
-	// Read object kind.
-	b := make([]byte, 2)
-	_, err := file.Read(b)
+// Read the entry at index.
+// The entry is verified, decompressed and the kind
+// mapped to the catalog kind.
+func (r *Reader) readEntry(index int) (kind uint16, bfr []byte, err error) {
+	// Lazy open.
+	r.open()
+	// Seek.
+	offset := r.index[index]
+	_, err = r.file.Seek(offset, io.SeekStart)
 	if err != nil {
-		if err != io.EOF {
-			panic(err)
-		}
+		err = liberr.Wrap(err)
 		return
 	}
-	kind = binary.LittleEndian.Uint16(b)
-	// Read object encoded length.
-	b = make([]byte, 8)
-	_, err = file.Read(b)
+	// Read entry.
+	kind, b, reason, err := readEntry(r.file)
 	if err != nil {
-		if err != io.EOF {
-			panic(err)
-		}
 		return
 	}
-	n := int64(binary.LittleEndian.Uint64(b))
-	// Read encoded object.
-	b = make([]byte, n)
-	_, err = file.Read(b)
-	if err != nil {
-		if err != io.EOF {
-			panic(err)
-		}
+	if reason != "" {
+		err = r.corrupt(index, reason)
 		return
 	}
+	// Decompress.
+	if kind&compressed != 0 {
+		kind &^= compressed
+		reader := flate.NewReader(bytes.NewReader(b))
+		b, err = ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			err = r.corrupt(index, "decompress failed: "+err.Error())
+			return
+		}
+	}
+	// Catalog kind.
+	if r.kinds != nil {
+		k, found := r.kinds[kind]
+		if !found {
+			err = r.corrupt(index, "kind not found in manifest")
+			return
+		}
+		kind = k
+	}
 
 	bfr = b
 
 	return
 }
 
+//
+// Build a corrupt entry error.
+func (r *Reader) corrupt(index int, reason string) error {
+	return &CorruptError{
+		Path:   r.path,
+		Index:  index,
+		Offset: r.index[index],
+		Reason: reason,
+	}
+}
+
 //
 // Open the reader.
 func (r *Reader) open() {
@@ -382,3 +752,111 @@ func (r *Reader) open() {
 
 	return
 }
+
+//
+// Verify the file at path.
+// Each entry is read sequentially and the checksum is
+// verified. Returns the corrupt entries. The scan stops
+// when the file is truncated.
+func Verify(path string) (bad []*CorruptError, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	offset := int64(0)
+	for index := 0; ; index++ {
+		var b []byte
+		var reason string
+		_, b, reason, err = readEntry(file)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			break
+		}
+		if reason != "" {
+			bad = append(
+				bad,
+				&CorruptError{
+					Path:   path,
+					Index:  index,
+					Offset: offset,
+					Reason: reason,
+				})
+			if b == nil {
+				break
+			}
+		}
+		offset += int64(headerSize + len(b))
+	}
+
+	log.V(5).Info(
+		"verified.",
+		"path",
+		path,
+		"corrupt",
+		len(bad))
+
+	return
+}
+
+//
+// Read the (stored) entry and verify the checksum.
+// The reason is set when the entry is corrupt; the object
+// is returned when the length (only) could be read.
+// Returns io.EOF when no entry.
+func readEntry(reader io.Reader) (kind uint16, b []byte, reason string, err error) {
+	// Read header.
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil {
+		switch err {
+		case io.EOF:
+		case io.ErrUnexpectedEOF:
+			err = nil
+			reason = fmt.Sprintf("header truncated (%d bytes)", n)
+		default:
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	kind = binary.LittleEndian.Uint16(header[0:2])
+	size := int64(binary.LittleEndian.Uint64(header[2:10]))
+	crc := binary.LittleEndian.Uint32(header[10:14])
+	if size < 0 {
+		reason = "invalid length"
+		return
+	}
+	// Read encoded object.
+	var bfr bytes.Buffer
+	nRead, err := io.CopyN(&bfr, reader, size)
+	if err != nil {
+		if err == io.EOF {
+			err = nil
+			reason = fmt.Sprintf("truncated (%d of %d bytes)", nRead, size)
+		} else {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	b = bfr.Bytes()
+	// Verify.
+	if checksum(header[0:10], b) != crc {
+		reason = "checksum mismatch"
+	}
+
+	return
+}
+
+//
+// Entry checksum.
+func checksum(header, object []byte) uint32 {
+	h := crc32.NewIEEE()
+	_, _ = h.Write(header)
+	_, _ = h.Write(object)
+	return h.Sum32()
+}
@@ -20,6 +20,25 @@ type Iterator interface {
 	Close()
 }
 
+//
+// Iterator reporting corrupt entries.
+// Implemented by file-backed iterators and adaptors.
+type TryIterator interface {
+	Iterator
+	// Object at index.
+	// Returns CorruptError when the entry is corrupt.
+	TryAt(index int) (interface{}, error)
+	// Object at index (with).
+	// Returns CorruptError when the entry is corrupt.
+	TryAtWith(int, interface{}) error
+	// Next object.
+	// Returns CorruptError when the entry is corrupt.
+	TryNext() (interface{}, bool, error)
+	// Next object (with).
+	// Returns CorruptError when the entry is corrupt.
+	TryNextWith(object interface{}) (bool, error)
+}
+
 //
 // Iterator.
 type FbIterator struct {
@@ -53,6 +72,32 @@ func (r *FbIterator) NextWith(object interface{}) (hasNext bool) {
 	return
 }
 
+//
+// Next object.
+// The position is advanced past corrupt entries.
+func (r *FbIterator) TryNext() (object interface{}, hasNext bool, err error) {
+	if r.current < r.Len() {
+		object, err = r.TryAt(r.current)
+		r.current++
+		hasNext = true
+	}
+
+	return
+}
+
+//
+// Next object.
+// The position is advanced past corrupt entries.
+func (r *FbIterator) TryNextWith(object interface{}) (hasNext bool, err error) {
+	if r.current < r.Len() {
+		err = r.TryAtWith(r.current, object)
+		r.current++
+		hasNext = true
+	}
+
+	return
+}
+
 //
 // Reverse the list.
 func (r *FbIterator) Reverse() {
@@ -108,7 +153,55 @@ func (*EmptyIterator) NextWith(object interface{}) bool {
 	return false
 }
 
+// Object at index.
+func (*EmptyIterator) TryAt(int) (interface{}, error) {
+	return nil, nil
+}
+
+// Object at index.
+func (*EmptyIterator) TryAtWith(int, interface{}) error {
+	return nil
+}
+
+//
+// Next object.
+func (*EmptyIterator) TryNext() (interface{}, bool, error) {
+	return nil, false, nil
+}
+
+//
+// Next object.
+func (*EmptyIterator) TryNextWith(object interface{}) (bool, error) {
+	return false, nil
+}
+
 //
 // Close the iterator.
 func (*EmptyIterator) Close() {
 }
+
+//
+// Object at index.
+// Errors are returned when the iterator is a TryIterator.
+func tryAt(itr Iterator, index int) (object interface{}, err error) {
+	if tItr, cast := itr.(TryIterator); cast {
+		object, err = tItr.TryAt(index)
+	} else {
+		object = itr.At(index)
+	}
+
+	return
+}
+
+//
+// Next object.
+// Errors are returned when the iterator is a TryIterator.
+func tryNext(itr Iterator) (object interface{}, hasNext bool, err error) {
+	if tItr, cast := itr.(TryIterator); cast {
+		object, hasNext, err = tItr.TryNext()
+	} else {
+		object, hasNext = itr.Next()
+	}
+
+	return
+}
@@ -1,64 +1,7 @@
-/*
-Provides file-backed list.
-
-//
-// New list.
-list := fb.NewList()
-
-//
-// Append an object.
-list.Append(object)
-
-//
-// Iterate the list.
-itr := list.Iter()
-for i := 0; i < itr.Len(); i++ {
-    person := itr.At(i)
-    ...
-}
-
-//
-// Iterate the list.
-itr := list.Iter()
-for i := 0; i < itr.Len(); i++ {
-    person := Person{}
-    itr.AtWith(i, &person))
-    ...
-}
-
-//
-// Iterate the list.
-itr := list.Iter()
-for {
-    object, hasNext := itr.Next()
-    if !hasNext {
-        break
-    }
-    ...
-}
-
-//
-// Iterate the list.
-itr := list.Iter()
-for object, hasNext := itr.Next(); hasNext; object, hasNext = itr.Next() {
-    ...
-}
-
-//
-// Iterate the list.
-itr := list.Iter()
-for {
-    person := Person{}
-    hasNext := itr.NextWith(&person))
-    if !hasNext {
-        break
-    }
-    ...
-}
-*/
 package filebacked
 
 import (
+	pathlib "path"
 	"runtime"
 )
 
@@ -66,11 +9,39 @@ import (
 // List factory.
 func NewList() (list *List) {
 	list = &List{}
+	list.writer.compression = Compression
+	runtime.SetFinalizer(
+		list,
+		func(l *List) {
+			l.Close()
+		})
+	return
+}
+
+//
+// Open a named (persistent) list.
+// The list is created when it does not exist. Relative
+// names are in the WorkingDir. The list is saved by
+// Flush() and Close() rather than deleted. Appended
+// types must be registered using Register().
+func OpenList(name string) (list *List, err error) {
+	path := name
+	if !pathlib.IsAbs(path) {
+		path = pathlib.Join(WorkingDir, name+Extension)
+	}
+	list = &List{}
+	list.writer.compression = Compression
+	err = list.writer.Load(path)
+	if err != nil {
+		list = nil
+		return
+	}
 	runtime.SetFinalizer(
 		list,
 		func(l *List) {
 			l.Close()
 		})
+
 	return
 }
 
@@ -81,23 +52,55 @@ type List struct {
 	writer Writer
 }
 
+//
+// Set the codec used to encode appended objects.
+// The codec is recorded per entry so it may be changed
+// at any time. The DefaultCodec is used when not set.
+func (l *List) SetCodec(codec Codec) {
+	l.writer.codec = codec
+}
+
+//
+// Set the (flate) compression level used to write
+// appended objects. Compression is recorded per entry
+// so it may be changed at any time. Direct access by
+// index is not affected.
+func (l *List) SetCompression(level int) {
+	l.writer.compression = level
+}
+
 //
 // Append an object.
 func (l *List) Append(object interface{}) {
+	err := l.TryAppend(object)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//
+// Append an object.
+// Returns CatalogFull when MaxKinds has been reached.
+func (l *List) TryAppend(object interface{}) (err error) {
 	switch object.(type) {
 	case Iterator:
 		itr := object.(Iterator)
 		for {
 			object, hasNext := itr.Next()
 			if hasNext {
-				l.writer.Append(object)
+				err = l.writer.TryAppend(object)
+				if err != nil {
+					return
+				}
 			} else {
 				break
 			}
 		}
 	default:
-		l.writer.Append(object)
+		err = l.writer.TryAppend(object)
 	}
+
+	return
 }
 
 //
@@ -121,6 +124,35 @@ func (l *List) AtWith(index int, object interface{}) {
 	return
 }
 
+// Object at index.
+// Returns CorruptError when the entry is corrupt.
+func (l *List) TryAt(index int) (object interface{}, err error) {
+	reader := l.writer.Reader(true)
+	object, err = reader.TryAt(index)
+	return
+}
+
+// Object at index.
+// Returns CorruptError when the entry is corrupt.
+func (l *List) TryAtWith(index int, object interface{}) (err error) {
+	reader := l.writer.Reader(true)
+	err = reader.TryAtWith(index, object)
+	return
+}
+
+//
+// Verify the list.
+// Returns the corrupt entries.
+func (l *List) Verify() (bad []*CorruptError, err error) {
+	if l.Len() == 0 {
+		return
+	}
+	l.writer.open()
+	l.writer.flush()
+	bad, err = Verify(l.writer.path)
+	return
+}
+
 //
 // Get an iterator.
 func (l *List) Iter() (itr Iterator) {
@@ -135,8 +167,18 @@ func (l *List) Iter() (itr Iterator) {
 	return
 }
 
+//
+// Flush (sync) the list.
+// For named lists, the index and kind manifest are saved
+// so the list may be reopened using OpenList().
+func (l *List) Flush() (err error) {
+	err = l.writer.Save()
+	return
+}
+
 //
 // Close (delete) the list.
+// Named lists are saved.
 func (l *List) Close() {
 	l.writer.Close()
 }
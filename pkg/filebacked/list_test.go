@@ -1,8 +1,15 @@
 package filebacked
 
 import (
+	"compress/flate"
+	"errors"
 	"fmt"
 	"github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	pathlib "path"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -191,6 +198,662 @@ func TestList(t *testing.T) {
 	g.Expect(listA.Len()).To(gomega.Equal(listB.Len()))
 }
 
+func TestCodec(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+
+	type Point struct {
+		X int32
+		Y int32
+	}
+
+	// Mixed codecs.
+	list := NewList()
+	defer list.Close()
+	list.Append(&Person{ID: 0, Name: "Elmer"})
+	list.SetCodec(JsonCodec)
+	list.Append(&Person{ID: 1, Name: "Fudd"})
+	list.SetCodec(BinaryCodec)
+	list.Append(&Point{X: 1, Y: 2})
+	list.SetCodec(nil)
+	list.Append(&Person{ID: 3, Name: "Bugs"})
+	g.Expect(list.Len()).To(gomega.Equal(4))
+
+	// Kind records the codec.
+	cat := &catalog
+	kinds := map[string]bool{}
+	for _, entry := range cat.content {
+		kinds[entry.codec.Name()] = true
+	}
+	g.Expect(kinds).To(gomega.HaveKey("gob"))
+	g.Expect(kinds).To(gomega.HaveKey("json"))
+	g.Expect(kinds).To(gomega.HaveKey("binary"))
+
+	// Decoded using the recorded codec.
+	itr := list.Iter()
+	defer itr.Close()
+	g.Expect(itr.At(0)).To(gomega.Equal(&Person{ID: 0, Name: "Elmer"}))
+	g.Expect(itr.At(1)).To(gomega.Equal(&Person{ID: 1, Name: "Fudd"}))
+	g.Expect(itr.At(2)).To(gomega.Equal(&Point{X: 1, Y: 2}))
+	g.Expect(itr.At(3)).To(gomega.Equal(&Person{ID: 3, Name: "Bugs"}))
+	person := &Person{}
+	itr.AtWith(1, person)
+	g.Expect(person.Name).To(gomega.Equal("Fudd"))
+	point := &Point{}
+	itr.AtWith(2, point)
+	g.Expect(point.Y).To(gomega.Equal(int32(2)))
+
+	// Unsupported.
+	list.SetCodec(BinaryCodec)
+	g.Expect(func() { list.Append(&Person{}) }).To(gomega.Panic())
+
+	// Not comparable.
+	list.SetCodec(SliceCodec{options: []string{"json"}})
+	list.Append(&Person{ID: 5, Name: "Daffy"})
+	list.Append(&Person{ID: 6, Name: "Porky"})
+	g.Expect(list.At(4)).To(gomega.Equal(&Person{ID: 5, Name: "Daffy"}))
+	g.Expect(list.At(5)).To(gomega.Equal(&Person{ID: 6, Name: "Porky"}))
+}
+
+func TestCatalogFull(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID int
+	}
+
+	cat := &Catalog{}
+	cat.content = make([]catalogEntry, MaxKinds-1)
+	for i := range cat.content {
+		cat.content[i] = catalogEntry{proto: i, codec: DefaultCodec}
+	}
+	kind, err := cat.add(&Person{}, DefaultCodec)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(int(kind)).To(gomega.Equal(MaxKinds - 1))
+	g.Expect(kind & compressed).To(gomega.Equal(uint16(0)))
+	again, err := cat.add(&Person{}, DefaultCodec)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(again).To(gomega.Equal(kind))
+	_, err = cat.add(&Person{}, JsonCodec)
+	g.Expect(errors.Is(err, CatalogFull)).To(gomega.BeTrue())
+}
+
+//
+// Codec (not comparable).
+type SliceCodec struct {
+	options []string
+}
+
+func (r SliceCodec) Name() string {
+	return "test"
+}
+
+func (r SliceCodec) Encode(object interface{}) ([]byte, error) {
+	return JsonCodec.Encode(object)
+}
+
+func (r SliceCodec) Decode(b []byte, object interface{}) error {
+	return JsonCodec.Decode(b, object)
+}
+
+//
+// Codec (decode fails).
+type BadCodec struct {
+}
+
+func (r BadCodec) Name() string {
+	return "bad"
+}
+
+func (r BadCodec) Encode(object interface{}) ([]byte, error) {
+	return JsonCodec.Encode(object)
+}
+
+func (r BadCodec) Decode(b []byte, object interface{}) error {
+	return errors.New("bad codec")
+}
+
+func TestCompression(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+		Text string
+	}
+
+	text := strings.Repeat("Elmer Fudd ", 100)
+
+	// Mixed compression.
+	list := NewList()
+	defer list.Close()
+	plain := NewList()
+	defer plain.Close()
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			list.SetCompression(flate.BestSpeed)
+		}
+		list.Append(&Person{ID: i, Name: "Elmer", Text: text})
+		plain.Append(&Person{ID: i, Name: "Elmer", Text: text})
+	}
+	// Not compressed when larger.
+	list.Append(1)
+
+	// Size reduced.
+	stA, err := os.Stat(list.writer.path)
+	g.Expect(err).To(gomega.BeNil())
+	stB, err := os.Stat(plain.writer.path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stA.Size() < stB.Size()).To(gomega.BeTrue())
+
+	// Direct access.
+	itr := list.Iter()
+	defer itr.Close()
+	g.Expect(itr.Len()).To(gomega.Equal(11))
+	for _, i := range []int{7, 2, 9, 0, 5} {
+		person := itr.At(i).(*Person)
+		g.Expect(person.ID).To(gomega.Equal(i))
+		g.Expect(person.Text).To(gomega.Equal(text))
+	}
+	g.Expect(*itr.At(10).(*int)).To(gomega.Equal(1))
+	person := &Person{}
+	itr.AtWith(6, person)
+	g.Expect(person.ID).To(gomega.Equal(6))
+}
+
+func TestNamedList(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	dir, err := ioutil.TempDir("", "fb")
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := pathlib.Join(dir, "people"+Extension)
+
+	// Not registered.
+	list, err := OpenList(path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(func() { list.Append(&Person{}) }).To(gomega.Panic())
+	list.Close()
+
+	Register("test.Person", &Person{})
+	Register("test.User", User{})
+	Register("test.Person", Person{})
+	g.Expect(func() { Register("test.Person", &User{}) }).To(gomega.Panic())
+	g.Expect(func() { Register("test.Other", &Person{}) }).To(gomega.Panic())
+
+	// Create.
+	list, err = OpenList(path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list.Len()).To(gomega.Equal(0))
+	for i := 0; i < 3; i++ {
+		list.Append(&Person{ID: i, Name: "Elmer"})
+	}
+	list.SetCodec(JsonCodec)
+	list.Append(&User{ID: 3, Name: "Fudd"})
+	list.Close()
+
+	// Reopen.
+	list, err = OpenList(path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list.Len()).To(gomega.Equal(4))
+	g.Expect(list.At(1)).To(gomega.Equal(&Person{ID: 1, Name: "Elmer"}))
+	g.Expect(list.At(3)).To(gomega.Equal(&User{ID: 3, Name: "Fudd"}))
+	list.Append(&Person{ID: 4, Name: "Bugs"})
+	err = list.Flush()
+	g.Expect(err).To(gomega.BeNil())
+	// Not flushed (lost).
+	list.Append(&Person{ID: 5, Name: "Daffy"})
+	_ = list.writer.file.Close()
+	list.writer.file = nil
+
+	// Reopen (after crash).
+	list, err = OpenList(path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list.Len()).To(gomega.Equal(5))
+	itr := list.Iter()
+	ids := []int{}
+	for {
+		object, hasNext := itr.Next()
+		if !hasNext {
+			break
+		}
+		switch object.(type) {
+		case *Person:
+			ids = append(ids, object.(*Person).ID)
+		case *User:
+			ids = append(ids, object.(*User).ID)
+		}
+	}
+	itr.Close()
+	g.Expect(ids).To(gomega.Equal([]int{0, 1, 2, 3, 4}))
+	offsets := list.writer.index
+	list.Close()
+
+	// Reopen (file shorter than index).
+	err = os.Truncate(path, offsets[3]+5)
+	g.Expect(err).To(gomega.BeNil())
+	_, err = OpenList(path)
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+	corrupt := &CorruptError{}
+	g.Expect(errors.As(err, &corrupt)).To(gomega.BeTrue())
+	g.Expect(corrupt.Index).To(gomega.Equal(3))
+	g.Expect(corrupt.Offset).To(gomega.Equal(offsets[3]))
+	info, err := os.Stat(path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(info.Size()).To(gomega.Equal(offsets[3] + 5))
+
+	// Anonymous list files are deleted.
+	anonymous := NewList()
+	anonymous.Append(1)
+	err = anonymous.Flush()
+	g.Expect(err).To(gomega.BeNil())
+	anonymous.Close()
+	_, err = os.Stat(anonymous.writer.path)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	_, err = os.Stat(anonymous.writer.path + IndexExtension)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+}
+
+func TestCorrupt(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+
+	list := NewList()
+	defer list.Close()
+	for i := 0; i < 5; i++ {
+		list.Append(&Person{ID: i, Name: "Elmer"})
+	}
+	bad, err := list.Verify()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(bad).To(gomega.BeEmpty())
+
+	// Corrupt entry 2.
+	file, err := os.OpenFile(list.writer.path, os.O_RDWR, 0)
+	g.Expect(err).To(gomega.BeNil())
+	b := make([]byte, 1)
+	at := list.writer.index[2] + headerSize + 1
+	_, err = file.ReadAt(b, at)
+	g.Expect(err).To(gomega.BeNil())
+	b[0] ^= 0xFF
+	_, err = file.WriteAt(b, at)
+	g.Expect(err).To(gomega.BeNil())
+	_ = file.Close()
+
+	// At.
+	_, err = list.TryAt(1)
+	g.Expect(err).To(gomega.BeNil())
+	_, err = list.TryAt(2)
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+	corrupt := &CorruptError{}
+	g.Expect(errors.As(err, &corrupt)).To(gomega.BeTrue())
+	g.Expect(corrupt.Index).To(gomega.Equal(2))
+	g.Expect(corrupt.Offset).To(gomega.Equal(list.writer.index[2]))
+	g.Expect(func() { list.At(2) }).To(gomega.Panic())
+	err = list.TryAtWith(2, &Person{})
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+
+	// Next.
+	itr := list.Iter()
+	ids := []int{}
+	nBad := 0
+	for {
+		object, hasNext, err := itr.(TryIterator).TryNext()
+		if !hasNext {
+			break
+		}
+		if err != nil {
+			g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+			nBad++
+			continue
+		}
+		ids = append(ids, object.(*Person).ID)
+	}
+	itr.Close()
+	g.Expect(ids).To(gomega.Equal([]int{0, 1, 3, 4}))
+	g.Expect(nBad).To(gomega.Equal(1))
+
+	// Filter.
+	itr = Filter(
+		list.Iter(),
+		func(object interface{}) bool {
+			return object.(*Person).ID != 3
+		})
+	g.Expect(itr.Len()).To(gomega.Equal(3))
+	object, err := itr.(TryIterator).TryAt(2)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.(*Person).ID).To(gomega.Equal(4))
+	itr.Close()
+	itr = Filter(
+		list.Iter(),
+		func(object interface{}) bool {
+			return object.(*Person).ID != 3
+		})
+	ids = []int{}
+	nBad = 0
+	for {
+		object, hasNext, err := itr.(TryIterator).TryNext()
+		if !hasNext {
+			break
+		}
+		if err != nil {
+			g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+			nBad++
+			continue
+		}
+		ids = append(ids, object.(*Person).ID)
+	}
+	itr.Close()
+	g.Expect(ids).To(gomega.Equal([]int{0, 1, 4}))
+	g.Expect(nBad).To(gomega.Equal(1))
+
+	// Verify.
+	bad, err = list.Verify()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(bad)).To(gomega.Equal(1))
+	g.Expect(bad[0].Index).To(gomega.Equal(2))
+
+	// Truncated.
+	err = os.Truncate(list.writer.path, list.writer.index[4]+3)
+	g.Expect(err).To(gomega.BeNil())
+	bad, err = Verify(list.writer.path)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(bad)).To(gomega.Equal(2))
+	g.Expect(bad[1].Index).To(gomega.Equal(4))
+	person := &Person{}
+	err = list.TryAtWith(4, person)
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+
+	// Decode failed.
+	undecoded := NewList()
+	defer undecoded.Close()
+	undecoded.SetCodec(BadCodec{})
+	undecoded.Append(&Person{ID: 0, Name: "Elmer"})
+	_, err = undecoded.TryAt(0)
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+	g.Expect(errors.As(err, &corrupt)).To(gomega.BeTrue())
+	g.Expect(corrupt.Index).To(gomega.Equal(0))
+	err = undecoded.TryAtWith(0, person)
+	g.Expect(errors.Is(err, Corrupt)).To(gomega.BeTrue())
+}
+
+func TestSort(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+
+	runSize := SortRunSize
+	SortRunSize = 7
+	defer func() {
+		SortRunSize = runSize
+	}()
+
+	list := NewList()
+	defer list.Close()
+	for i := 0; i < 50; i++ {
+		list.Append(&Person{ID: (i * 37) % 50, Name: strconv.Itoa(i % 3)})
+	}
+	byID := func(a, b interface{}) bool {
+		return a.(*Person).ID < b.(*Person).ID
+	}
+	sorted := list.Sort(byID)
+	defer sorted.Close()
+	g.Expect(sorted.Len()).To(gomega.Equal(50))
+	for i := 0; i < sorted.Len(); i++ {
+		g.Expect(sorted.At(i).(*Person).ID).To(gomega.Equal(i))
+	}
+
+	// Stable.
+	byName := func(a, b interface{}) bool {
+		return a.(*Person).Name < b.(*Person).Name
+	}
+	sorted2 := sorted.Sort(byName)
+	defer sorted2.Close()
+	last := &Person{ID: -1}
+	for i := 0; i < sorted2.Len(); i++ {
+		person := sorted2.At(i).(*Person)
+		if person.Name == last.Name {
+			g.Expect(person.ID > last.ID).To(gomega.BeTrue())
+		} else {
+			g.Expect(person.Name > last.Name).To(gomega.BeTrue())
+		}
+		last = person
+	}
+
+	// Empty.
+	empty := NewList().Sort(byID)
+	g.Expect(empty.Len()).To(gomega.Equal(0))
+}
+
+func TestHashMap(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+
+	capacity := MapCapacity
+	MapCapacity = 4
+	defer func() {
+		MapCapacity = capacity
+	}()
+
+	mp := NewHashMap()
+	defer mp.Close()
+	_, found := mp.Get("none")
+	g.Expect(found).To(gomega.BeFalse())
+	g.Expect(mp.Delete("none")).To(gomega.BeFalse())
+	for i := 0; i < 100; i++ {
+		mp.Put(strconv.Itoa(i), &Person{ID: i, Name: "Elmer"})
+	}
+	g.Expect(mp.Len()).To(gomega.Equal(100))
+	g.Expect(mp.index.capacity > 100).To(gomega.BeTrue())
+
+	// Get.
+	for i := 0; i < 100; i++ {
+		object, found := mp.Get(strconv.Itoa(i))
+		g.Expect(found).To(gomega.BeTrue())
+		g.Expect(object.(*Person).ID).To(gomega.Equal(i))
+	}
+
+	// Replace.
+	mp.Put("10", &Person{ID: 10, Name: "Fudd"})
+	g.Expect(mp.Len()).To(gomega.Equal(100))
+	person := &Person{}
+	g.Expect(mp.GetWith("10", person)).To(gomega.BeTrue())
+	g.Expect(person.Name).To(gomega.Equal("Fudd"))
+
+	// Delete.
+	for i := 0; i < 100; i += 2 {
+		g.Expect(mp.Delete(strconv.Itoa(i))).To(gomega.BeTrue())
+	}
+	g.Expect(mp.Len()).To(gomega.Equal(50))
+	_, found = mp.Get("10")
+	g.Expect(found).To(gomega.BeFalse())
+	_, found = mp.Get("11")
+	g.Expect(found).To(gomega.BeTrue())
+	mp.Put("10", &Person{ID: 10, Name: "Bugs"})
+	g.Expect(mp.Len()).To(gomega.Equal(51))
+
+	// Iter.
+	itr := mp.Iter()
+	g.Expect(itr.Len()).To(gomega.Equal(51))
+	ids := []int{}
+	for {
+		object, hasNext := itr.Next()
+		if !hasNext {
+			break
+		}
+		ids = append(ids, object.(*Person).ID)
+	}
+	itr.Close()
+	g.Expect(ids[0]).To(gomega.Equal(1))
+	g.Expect(ids[50]).To(gomega.Equal(10))
+	keys := mp.Keys()
+	g.Expect(*keys.At(50).(*string)).To(gomega.Equal("10"))
+	keys.Close()
+
+	// Compaction.
+	compaction := MapCompaction
+	MapCompaction = 10
+	defer func() {
+		MapCompaction = compaction
+	}()
+	itr = mp.Iter()
+	for n := 0; n < 1000; n++ {
+		mp.Put("11", &Person{ID: 11, Name: strconv.Itoa(n)})
+	}
+	g.Expect(mp.Len()).To(gomega.Equal(51))
+	g.Expect(len(mp.writer.index) < 4*51).To(gomega.BeTrue())
+	g.Expect(mp.GetWith("11", person)).To(gomega.BeTrue())
+	g.Expect(person.Name).To(gomega.Equal("999"))
+	g.Expect(mp.GetWith("10", person)).To(gomega.BeTrue())
+	g.Expect(person.Name).To(gomega.Equal("Bugs"))
+	_, found = mp.Get("12")
+	g.Expect(found).To(gomega.BeFalse())
+	keys = mp.Keys()
+	g.Expect(*keys.At(0).(*string)).To(gomega.Equal("1"))
+	g.Expect(*keys.At(49).(*string)).To(gomega.Equal("10"))
+	g.Expect(*keys.At(50).(*string)).To(gomega.Equal("11"))
+	keys.Close()
+	g.Expect(itr.Len()).To(gomega.Equal(51))
+	g.Expect(itr.At(50).(*Person).Name).To(gomega.Equal("Bugs"))
+	itr.Close()
+}
+
+func TestAdaptor(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	numbers := func(n int) Iterator {
+		list := NewList()
+		for i := 0; i < n; i++ {
+			list.Append(i)
+		}
+		itr := list.Iter()
+		list.Close()
+		return itr
+	}
+	collect := func(itr Iterator) (all []interface{}) {
+		defer itr.Close()
+		all = []interface{}{}
+		for {
+			object, hasNext := itr.Next()
+			if !hasNext {
+				break
+			}
+			switch object.(type) {
+			case *int:
+				object = *object.(*int)
+			}
+			all = append(all, object)
+		}
+		return
+	}
+	even := func(object interface{}) bool {
+		return *object.(*int)%2 == 0
+	}
+	double := func(object interface{}) interface{} {
+		return *object.(*int) * 2
+	}
+
+	// Filter.
+	itr := Filter(numbers(10), even)
+	g.Expect(itr.At(2)).To(gomega.Equal(intPtr(4)))
+	g.Expect(itr.Len()).To(gomega.Equal(5))
+	g.Expect(collect(Filter(numbers(10), even))).To(
+		gomega.Equal([]interface{}{0, 2, 4, 6, 8}))
+	n := 0
+	itr = Filter(numbers(10), even)
+	g.Expect(itr.NextWith(&n)).To(gomega.BeTrue())
+	g.Expect(itr.NextWith(&n)).To(gomega.BeTrue())
+	g.Expect(n).To(gomega.Equal(2))
+	itr.Close()
+
+	// Transform.
+	itr = Map(numbers(3), double)
+	g.Expect(itr.Len()).To(gomega.Equal(3))
+	g.Expect(itr.At(2)).To(gomega.Equal(4))
+	g.Expect(collect(itr)).To(gomega.Equal([]interface{}{0, 2, 4}))
+
+	// Concat.
+	itr = Concat(numbers(2), Filter(numbers(6), even), numbers(1))
+	g.Expect(itr.At(3)).To(gomega.Equal(intPtr(2)))
+	g.Expect(itr.Len()).To(gomega.Equal(6))
+	g.Expect(collect(itr)).To(gomega.Equal([]interface{}{0, 1, 0, 2, 4, 0}))
+	matched := 0
+	itr = Concat(
+		Take(
+			Filter(
+				numbers(100),
+				func(object interface{}) bool {
+					matched++
+					return true
+				}),
+			2),
+		numbers(1))
+	g.Expect(itr.At(2)).To(gomega.Equal(intPtr(0)))
+	g.Expect(collect(itr)).To(gomega.Equal([]interface{}{0, 1, 0}))
+	g.Expect(matched).To(gomega.BeNumerically("<", 10))
+
+	// Batch.
+	itr = Batch(numbers(5), 2)
+	g.Expect(itr.Len()).To(gomega.Equal(3))
+	g.Expect(itr.At(2)).To(gomega.Equal([]interface{}{intPtr(4)}))
+	itr.Close()
+
+	// Take and Skip.
+	g.Expect(collect(Take(numbers(5), 2))).To(gomega.Equal([]interface{}{0, 1}))
+	g.Expect(collect(Take(numbers(1), 2))).To(gomega.Equal([]interface{}{0}))
+	g.Expect(collect(Skip(numbers(5), 3))).To(gomega.Equal([]interface{}{3, 4}))
+	g.Expect(Skip(numbers(2), 3).Len()).To(gomega.Equal(0))
+	g.Expect(Take(numbers(2), -1).Len()).To(gomega.Equal(0))
+	g.Expect(collect(Take(numbers(2), -1))).To(gomega.BeEmpty())
+	g.Expect(Skip(numbers(2), -1).Len()).To(gomega.Equal(2))
+	g.Expect(collect(Skip(numbers(2), -1))).To(gomega.Equal([]interface{}{0, 1}))
+	g.Expect(collect(Take(Skip(Filter(numbers(20), even), 2), 3))).To(
+		gomega.Equal([]interface{}{4, 6, 8}))
+
+	// Zip.
+	itr = Zip(numbers(3), Map(numbers(5), double))
+	g.Expect(itr.Len()).To(gomega.Equal(3))
+	g.Expect(itr.At(2)).To(gomega.Equal([]interface{}{intPtr(2), 4}))
+	itr.Close()
+
+	// Reverse.
+	itr = Filter(numbers(10), even)
+	itr.Reverse()
+	g.Expect(collect(itr)).To(gomega.Equal([]interface{}{8, 6, 4, 2, 0}))
+}
+
+//
+// Pointer to int.
+func intPtr(n int) *int {
+	return &n
+}
+
 // Disabled by default.
 func __TestListPerf(t *testing.T) {
 	list := NewList()
@@ -0,0 +1,437 @@
+package filebacked
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"os"
+	"runtime"
+	"sort"
+)
+
+//
+// Initial number of (hash) index slots.
+var MapCapacity = uint64(1024)
+
+//
+// Minimum number of stale entries before the
+// file is compacted.
+var MapCompaction = uint64(1024)
+
+//
+// HashMap factory.
+func NewHashMap() (mp *HashMap) {
+	mp = &HashMap{}
+	mp.writer.compression = Compression
+	runtime.SetFinalizer(
+		mp,
+		func(m *HashMap) {
+			m.Close()
+		})
+	return
+}
+
+//
+// File-backed map.
+// Maps (string) keys to objects. Each Put() appends the
+// key and object to the file. The on-disk (open addressing)
+// hash index maps the key hash to the position of the
+// latest entry. The file is compacted when the stale
+// (replaced or deleted) entries exceed the live ones.
+// Not safe for concurrent use; callers sharing a map
+// between goroutines must synchronize access.
+type HashMap struct {
+	// File writer.
+	writer Writer
+	// Hash index.
+	index hashIndex
+}
+
+//
+// Put (add or replace) the object.
+func (m *HashMap) Put(key string, object interface{}) {
+	m.index.open()
+	m.grow()
+	slot, state, _ := m.find(key)
+	position := uint64(len(m.writer.index))
+	m.writer.Append(key)
+	m.writer.Append(object)
+	m.index.set(slot, position+1, hashOf(key))
+	switch state {
+	case slotEmpty:
+		m.index.used++
+		m.index.count++
+	case slotDeleted:
+		m.index.count++
+	}
+}
+
+//
+// Get the object.
+func (m *HashMap) Get(key string) (object interface{}, found bool) {
+	_, state, position := m.find(key)
+	if state == slotUsed {
+		object = m.reader().At(int(position) + 1)
+		found = true
+	}
+
+	return
+}
+
+//
+// Get the object (with).
+func (m *HashMap) GetWith(key string, object interface{}) (found bool) {
+	_, state, position := m.find(key)
+	if state == slotUsed {
+		m.reader().AtWith(int(position)+1, object)
+		found = true
+	}
+
+	return
+}
+
+//
+// Delete the object.
+func (m *HashMap) Delete(key string) (found bool) {
+	slot, state, _ := m.find(key)
+	if state == slotUsed {
+		m.index.set(slot, slotDeleted, 0)
+		m.index.count--
+		found = true
+	}
+
+	return
+}
+
+//
+// Length.
+// Number of keys.
+func (m *HashMap) Len() int {
+	return int(m.index.count)
+}
+
+//
+// Get an iterator.
+// The objects are in the order (last) put.
+func (m *HashMap) Iter() (itr Iterator) {
+	itr = m.iter(1)
+	return
+}
+
+//
+// Get an iterator.
+// The keys are in the order (last) put.
+func (m *HashMap) Keys() (itr Iterator) {
+	itr = m.iter(0)
+	return
+}
+
+//
+// Close (delete) the map.
+func (m *HashMap) Close() {
+	m.writer.Close()
+	m.index.close()
+}
+
+//
+// Build an iterator of the latest entries.
+// The delta selects the key (0) or the object (1).
+func (m *HashMap) iter(delta int) (itr Iterator) {
+	if m.Len() == 0 {
+		itr = &EmptyIterator{}
+		return
+	}
+	positions := m.positions()
+	reader := m.writer.Reader(false)
+	index := make([]int64, 0, len(positions))
+	for _, position := range positions {
+		index = append(index, reader.index[position+delta])
+	}
+	reader.index = index
+	itr = &FbIterator{
+		Reader: reader,
+	}
+
+	return
+}
+
+//
+// (Entry) positions of the latest entries.
+// Sorted in the order put.
+func (m *HashMap) positions() (positions []int) {
+	for slot := uint64(0); slot < m.index.capacity; slot++ {
+		position, _ := m.index.get(slot)
+		if position != slotEmpty && position != slotDeleted {
+			positions = append(positions, int(position-1))
+		}
+	}
+	sort.Ints(positions)
+	return
+}
+
+//
+// Grow (rehash) the index when 3/4 of the slots are
+// used (or deleted). The index is also rehashed when
+// the file is compacted.
+func (m *HashMap) grow() {
+	relocated := m.compact()
+	if relocated == nil && !m.index.full() {
+		return
+	}
+	m.index.rehash(relocated)
+}
+
+//
+// Compact (rewrite) the file when the stale entries
+// exceed the live ones. The latest entries are copied
+// to a new file in the order put.
+// Returns the relocated (old => new) slot entries.
+func (m *HashMap) compact() (relocated map[uint64]uint64) {
+	live := m.index.count
+	stale := uint64(len(m.writer.index)/2) - live
+	if stale <= live || stale < MapCompaction {
+		return
+	}
+	reader := m.reader()
+	writer := Writer{
+		codec:       m.writer.codec,
+		compression: m.writer.compression,
+	}
+	writer.open()
+	relocated = make(map[uint64]uint64)
+	for _, position := range m.positions() {
+		relocated[uint64(position)+1] = uint64(len(writer.index)) + 1
+		for _, index := range []int{position, position + 1} {
+			kind, b, err := reader.readEntry(index)
+			if err != nil {
+				writer.Close()
+				panic(err)
+			}
+			offset := writer.writeEntry(kind, b)
+			writer.index = append(writer.index, offset)
+		}
+	}
+	writer.dirty = true
+	m.writer.Close()
+	m.writer = writer
+
+	log.V(5).Info(
+		"map: compacted.",
+		"path",
+		m.writer.path,
+		"stale",
+		stale)
+
+	return
+}
+
+//
+// Find the slot for the key.
+// Returns the slot to be used for the key, the state
+// of the slot and the (entry) position of the key.
+func (m *HashMap) find(key string) (slot, state, position uint64) {
+	state = slotEmpty
+	if m.index.file == nil {
+		return
+	}
+	reader := m.reader()
+	hash := hashOf(key)
+	mask := m.index.capacity - 1
+	deleted := false
+	for n := uint64(0); n < m.index.capacity; n++ {
+		next := (hash + n) & mask
+		entry, h := m.index.get(next)
+		switch entry {
+		case slotEmpty:
+			if !deleted {
+				slot = next
+			}
+			return
+		case slotDeleted:
+			if !deleted {
+				slot = next
+				state = slotDeleted
+				deleted = true
+			}
+			continue
+		}
+		if h != hash {
+			continue
+		}
+		stored := ""
+		reader.AtWith(int(entry-1), &stored)
+		if stored == key {
+			slot = next
+			state = slotUsed
+			position = entry - 1
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Shared reader.
+func (m *HashMap) reader() *Reader {
+	m.writer.open()
+	return &Reader{
+		index:  m.writer.index,
+		path:   m.writer.path,
+		file:   m.writer.file,
+		shared: true,
+	}
+}
+
+//
+// Slot (entry) states.
+// Used slots contain the entry position + 1.
+const (
+	slotEmpty   = uint64(0)
+	slotDeleted = ^uint64(0)
+	slotUsed    = uint64(1)
+)
+
+//
+// Hash index slot size.
+// Slot format:
+//   | entry: 8 (uint64)
+//   | hash: 8 (uint64)
+const slotSize = 16
+
+//
+// On-disk (open addressing) hash index.
+type hashIndex struct {
+	// File path.
+	path string
+	// File.
+	file *os.File
+	// Number of slots (power of 2).
+	capacity uint64
+	// Number of keys.
+	count uint64
+	// Number of (used|deleted) slots.
+	used uint64
+}
+
+//
+// Open the index.
+func (r *hashIndex) open() {
+	if r.file != nil {
+		return
+	}
+	r.capacity = MapCapacity
+	if r.capacity < 2 || r.capacity&(r.capacity-1) != 0 {
+		r.capacity = 1024
+	}
+	r.path, r.file = r.create(r.capacity)
+}
+
+//
+// Create an (empty) index file.
+func (r *hashIndex) create(capacity uint64) (path string, file *os.File) {
+	path = (&Writer{}).newPath() + IndexExtension
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	err = file.Truncate(int64(capacity * slotSize))
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+//
+// The index is full when 3/4 of the slots are
+// used (or deleted).
+func (r *hashIndex) full() bool {
+	return (r.used+1)*4 > r.capacity*3
+}
+
+//
+// Rehash the index.
+// Deleted slots are dropped and the entries are mapped
+// through relocated (when not nil).
+func (r *hashIndex) rehash(relocated map[uint64]uint64) {
+	capacity := r.capacity
+	if r.count*2 >= r.capacity {
+		capacity *= 2
+	}
+	path, file := r.create(capacity)
+	old := *r
+	r.path = path
+	r.file = file
+	r.capacity = capacity
+	r.used = 0
+	mask := capacity - 1
+	for slot := uint64(0); slot < old.capacity; slot++ {
+		entry, hash := old.get(slot)
+		if entry == slotEmpty || entry == slotDeleted {
+			continue
+		}
+		if relocated != nil {
+			entry = relocated[entry]
+		}
+		for n := uint64(0); ; n++ {
+			next := (hash + n) & mask
+			if e, _ := r.get(next); e == slotEmpty {
+				r.set(next, entry, hash)
+				r.used++
+				break
+			}
+		}
+	}
+	old.close()
+
+	log.V(5).Info(
+		"map: index rehashed.",
+		"path",
+		r.path,
+		"capacity",
+		r.capacity)
+}
+
+//
+// Get the slot.
+func (r *hashIndex) get(slot uint64) (entry, hash uint64) {
+	b := make([]byte, slotSize)
+	_, err := r.file.ReadAt(b, int64(slot*slotSize))
+	if err != nil {
+		panic(err)
+	}
+	entry = binary.LittleEndian.Uint64(b[0:8])
+	hash = binary.LittleEndian.Uint64(b[8:16])
+	return
+}
+
+//
+// Set the slot.
+func (r *hashIndex) set(slot, entry, hash uint64) {
+	b := make([]byte, slotSize)
+	binary.LittleEndian.PutUint64(b[0:8], entry)
+	binary.LittleEndian.PutUint64(b[8:16], hash)
+	_, err := r.file.WriteAt(b, int64(slot*slotSize))
+	if err != nil {
+		panic(err)
+	}
+}
+
+//
+// Close (delete) the index.
+func (r *hashIndex) close() {
+	if r.file == nil {
+		return
+	}
+	_ = r.file.Close()
+	_ = os.Remove(r.path)
+	r.file = nil
+}
+
+//
+// Key hash.
+func hashOf(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
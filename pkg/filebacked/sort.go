@@ -0,0 +1,187 @@
+package filebacked
+
+import (
+	"container/heap"
+	"sort"
+)
+
+//
+// Number of objects sorted in memory (per run)
+// by the external merge sort.
+var SortRunSize = 10000
+
+//
+// Comparator.
+// Returns true when object `a` sorts before object `b`.
+type Less func(a, b interface{}) bool
+
+//
+// Sort the list.
+// Returns a new (sorted) list.
+func (l *List) Sort(less Less) (sorted *List) {
+	itr := l.Iter()
+	defer itr.Close()
+	sorted = Sort(itr, less)
+	return
+}
+
+//
+// Sort the objects using an external merge sort.
+// Runs of SortRunSize objects are sorted in memory and
+// spilled to lists which are then merged. The sort is
+// stable. Returns a new (sorted) list.
+func Sort(itr Iterator, less Less) (sorted *List) {
+	runs := []*List{}
+	defer func() {
+		for _, run := range runs {
+			run.Close()
+		}
+	}()
+	objects := []interface{}{}
+	spill := func() {
+		if len(objects) == 0 {
+			return
+		}
+		sort.SliceStable(
+			objects,
+			func(i, j int) bool {
+				return less(objects[i], objects[j])
+			})
+		run := NewList()
+		for _, object := range objects {
+			run.Append(object)
+		}
+		runs = append(runs, run)
+		objects = []interface{}{}
+	}
+	for {
+		object, hasNext := itr.Next()
+		if !hasNext {
+			break
+		}
+		objects = append(objects, object)
+		if len(objects) >= SortRunSize {
+			spill()
+		}
+	}
+	spill()
+	nRuns := len(runs)
+	switch nRuns {
+	case 0:
+		sorted = NewList()
+	case 1:
+		sorted = runs[0]
+		runs = nil
+	default:
+		sorted = merge(runs, less)
+	}
+
+	log.V(5).Info(
+		"sorted.",
+		"runs",
+		nRuns,
+		"length",
+		sorted.Len())
+
+	return
+}
+
+//
+// Merge (sorted) runs.
+func merge(runs []*List, less Less) (merged *List) {
+	merged = NewList()
+	h := &mergeHeap{less: less}
+	for n, run := range runs {
+		itr := run.Iter()
+		object, hasNext := itr.Next()
+		if hasNext {
+			h.items = append(
+				h.items,
+				&mergeItem{
+					object: object,
+					itr:    itr,
+					run:    n,
+				})
+		} else {
+			itr.Close()
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		item := h.items[0]
+		merged.Append(item.object)
+		object, hasNext := item.itr.Next()
+		if hasNext {
+			item.object = object
+			heap.Fix(h, 0)
+		} else {
+			item.itr.Close()
+			heap.Pop(h)
+		}
+	}
+
+	return
+}
+
+//
+// Merge heap item.
+type mergeItem struct {
+	// Current object.
+	object interface{}
+	// Run iterator.
+	itr Iterator
+	// Run number.
+	run int
+}
+
+//
+// Merge heap.
+// Ordered by object then run (stable).
+type mergeHeap struct {
+	// Items.
+	items []*mergeItem
+	// Comparator.
+	less Less
+}
+
+//
+// Length.
+func (r *mergeHeap) Len() int {
+	return len(r.items)
+}
+
+//
+// Item i before item j.
+func (r *mergeHeap) Less(i, j int) bool {
+	a := r.items[i]
+	b := r.items[j]
+	if r.less(a.object, b.object) {
+		return true
+	}
+	if r.less(b.object, a.object) {
+		return false
+	}
+
+	return a.run < b.run
+}
+
+//
+// Swap items.
+func (r *mergeHeap) Swap(i, j int) {
+	r.items[i], r.items[j] = r.items[j], r.items[i]
+}
+
+//
+// Push an item.
+func (r *mergeHeap) Push(item interface{}) {
+	r.items = append(r.items, item.(*mergeItem))
+}
+
+//
+// Pop the last item.
+func (r *mergeHeap) Pop() (item interface{}) {
+	last := len(r.items) - 1
+	item = r.items[last]
+	r.items = r.items[:last]
+	return
+}
@@ -46,10 +46,99 @@ type Disposition struct {
 	desired *dpnModel
 }
 
+//
+// Disposition set.
+type dispositionSet interface {
+	// Call the function for each disposition.
+	forEach(fn func(dpn *Disposition) error) error
+	// Release resources.
+	close()
+}
+
 //
 // Disposition map.
 type Dispositions map[string]*Disposition
 
+//
+// Call the function for each disposition.
+func (r Dispositions) forEach(fn func(dpn *Disposition) error) (err error) {
+	for _, dpn := range r {
+		err = fn(dpn)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Release resources.
+func (r Dispositions) close() {
+}
+
+//
+// Disposition (indexes).
+// Stored in the file-backed map.
+type dpnIndex struct {
+	// Index within the stored models (-1=none).
+	Stored int
+	// Index within the desired models (-1=none).
+	Desired int
+}
+
+//
+// File-backed dispositions.
+type fbDispositions struct {
+	// Stored (only) disposition indexes by Pk.
+	mp *fb.HashMap
+	// Desired disposition indexes by Pk.
+	desiredMp *fb.HashMap
+	// Stored models.
+	stored fb.Iterator
+	// Desired models.
+	desired fb.Iterator
+}
+
+//
+// Call the function for each disposition.
+func (r *fbDispositions) forEach(fn func(dpn *Disposition) error) (err error) {
+	itr := fb.Concat(r.desiredMp.Iter(), r.mp.Iter())
+	defer itr.Close()
+	for {
+		index := dpnIndex{}
+		if !itr.NextWith(&index) {
+			break
+		}
+		dpn := &Disposition{}
+		if index.Stored >= 0 {
+			dpn.stored = &dpnModel{
+				itr:   r.stored,
+				index: index.Stored,
+			}
+		}
+		if index.Desired >= 0 {
+			dpn.desired = &dpnModel{
+				itr:   r.desired,
+				index: index.Desired,
+			}
+		}
+		err = fn(dpn)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Release resources.
+func (r *fbDispositions) close() {
+	r.mp.Close()
+	r.desiredMp.Close()
+}
+
 //
 // Model collection.
 type Collection struct {
@@ -59,6 +148,9 @@ type Collection struct {
 	Tx *model.Tx
 	// An (optional) shepherd.
 	Shepherd Shepherd
+	// Build the dispositions using a file-backed map
+	// rather than memory. Used for large collections.
+	DiskBacked bool
 	// Number of models added.
 	Added int
 	// Number models updated.
@@ -70,35 +162,42 @@ type Collection struct {
 //
 // Add models included in desired but not stored.
 func (r *Collection) Add(desired fb.Iterator) error {
-	return r.add(r.dispositions(desired))
+	dispositions := r.dispositions(desired)
+	defer dispositions.close()
+	return r.add(dispositions)
 }
 
 //
 // Update models.
 func (r *Collection) Update(desired fb.Iterator) error {
-	return r.update(r.dispositions(desired))
+	dispositions := r.dispositions(desired)
+	defer dispositions.close()
+	return r.update(dispositions)
 }
 
 //
 // Delete stored models not included in the desired.
 func (r *Collection) Delete(desired fb.Iterator) error {
-	return r.delete(r.dispositions(desired))
+	dispositions := r.dispositions(desired)
+	defer dispositions.close()
+	return r.delete(dispositions)
 }
 
 //
 // Reconcile the collection.
 // Ensure the stored collection is as desired.
 func (r *Collection) Reconcile(desired fb.Iterator) (err error) {
-	mp := r.dispositions(desired)
-	err = r.delete(mp)
+	dispositions := r.dispositions(desired)
+	defer dispositions.close()
+	err = r.delete(dispositions)
 	if err != nil {
 		return
 	}
-	err = r.add(mp)
+	err = r.add(dispositions)
 	if err != nil {
 		return
 	}
-	err = r.update(mp)
+	err = r.update(dispositions)
 	if err != nil {
 		return
 	}
@@ -108,7 +207,61 @@ func (r *Collection) Reconcile(desired fb.Iterator) (err error) {
 
 //
 // Build the dispositions.
-func (r *Collection) dispositions(desired fb.Iterator) (mp map[string]*Disposition) {
+func (r *Collection) dispositions(desired fb.Iterator) dispositionSet {
+	if r.DiskBacked {
+		return r.fbDispositions(desired)
+	}
+
+	return r.mapDispositions(desired)
+}
+
+//
+// Build the (file-backed) dispositions.
+// The stored indexes are put (once) in the map. Each
+// desired index is put in the desired map and the matched
+// stored index is moved from the map. The map retains
+// the stored (only) indexes. As with the in-memory
+// dispositions, the last desired model wins when the
+// Pk is duplicated.
+func (r *Collection) fbDispositions(desired fb.Iterator) (dispositions *fbDispositions) {
+	dispositions = &fbDispositions{
+		mp:        fb.NewHashMap(),
+		desiredMp: fb.NewHashMap(),
+		stored:    r.Stored,
+		desired:   desired,
+	}
+	mp := dispositions.mp
+	for i := 0; i < r.Stored.Len(); i++ {
+		object := r.Stored.At(i)
+		m := object.(model.Model)
+		mp.Put(
+			m.Pk(),
+			&dpnIndex{
+				Stored:  i,
+				Desired: -1,
+			})
+	}
+	for i := 0; i < desired.Len(); i++ {
+		object := desired.At(i)
+		m := object.(model.Model)
+		index := &dpnIndex{}
+		if !dispositions.desiredMp.GetWith(m.Pk(), index) {
+			if mp.GetWith(m.Pk(), index) {
+				mp.Delete(m.Pk())
+			} else {
+				index.Stored = -1
+			}
+		}
+		index.Desired = i
+		dispositions.desiredMp.Put(m.Pk(), index)
+	}
+
+	return
+}
+
+//
+// Build the (in-memory) dispositions.
+func (r *Collection) mapDispositions(desired fb.Iterator) (mp Dispositions) {
 	mp = map[string]*Disposition{}
 	for i := 0; i < r.Stored.Len(); i++ {
 		object := r.Stored.At(i)
@@ -143,62 +296,62 @@ func (r *Collection) dispositions(desired fb.Iterator) (mp map[string]*Dispositi
 
 //
 // Add models included in desired but not stored.
-func (r *Collection) add(dispositions Dispositions) (err error) {
-	for _, dpn := range dispositions {
-		if dpn.desired != nil && dpn.stored == nil {
-			err = r.Tx.Insert(dpn.desired.model())
-			if err == nil {
-				r.Added++
-			} else {
-				return
+func (r *Collection) add(dispositions dispositionSet) (err error) {
+	err = dispositions.forEach(
+		func(dpn *Disposition) (err error) {
+			if dpn.desired != nil && dpn.stored == nil {
+				err = r.Tx.Insert(dpn.desired.model())
+				if err == nil {
+					r.Added++
+				}
 			}
-		}
-	}
+			return
+		})
 
 	return
 }
 
 //
 // Update models.
-func (r *Collection) update(dispositions Dispositions) (err error) {
+func (r *Collection) update(dispositions dispositionSet) (err error) {
 	shepherd := r.Shepherd
 	if shepherd == nil {
 		shepherd = &DefaultShepherd{}
 	}
-	for _, dpn := range dispositions {
-		if dpn.desired == nil || dpn.stored == nil {
-			continue
-		}
-		desired := dpn.desired.model()
-		stored := dpn.stored.model()
-		if shepherd.Equals(desired, stored) {
-			continue
-		}
-		shepherd.Update(stored, desired)
-		err = r.Tx.Update(stored)
-		if err == nil {
-			r.Updated++
-		} else {
+	err = dispositions.forEach(
+		func(dpn *Disposition) (err error) {
+			if dpn.desired == nil || dpn.stored == nil {
+				return
+			}
+			desired := dpn.desired.model()
+			stored := dpn.stored.model()
+			if shepherd.Equals(desired, stored) {
+				return
+			}
+			shepherd.Update(stored, desired)
+			err = r.Tx.Update(stored)
+			if err == nil {
+				r.Updated++
+			}
 			return
-		}
-	}
+		})
 
 	return
 }
 
 //
 // Delete stored models not included in the desired.
-func (r *Collection) delete(dispositions Dispositions) (err error) {
-	for _, dpn := range dispositions {
-		if dpn.stored != nil && dpn.desired == nil {
-			err = r.Tx.Delete(dpn.stored.model())
-			if err == nil {
-				r.Deleted++
-			} else {
-				return
+func (r *Collection) delete(dispositions dispositionSet) (err error) {
+	err = dispositions.forEach(
+		func(dpn *Disposition) (err error) {
+			if dpn.stored != nil && dpn.desired == nil {
+				err = r.Tx.Delete(dpn.stored.model())
+				if err == nil {
+					r.Deleted++
+				}
 			}
-		}
-	}
+			return
+		})
 
 	return
 }
@@ -64,6 +64,7 @@ func TestCollection(t *testing.T) {
 		model.ListOptions{
 			Detail: model.MaxDetail,
 		})
+	g.Expect(err).To(gomega.BeNil())
 	for i := 11; i < 15; i++ {
 		desired = append(
 			desired, TestObject2{
@@ -81,6 +82,7 @@ func TestCollection(t *testing.T) {
 		Tx:     tx,
 	}
 	err = collection.Add(asIter(desired))
+	g.Expect(err).To(gomega.BeNil())
 	_ = tx.Commit()
 	g.Expect(collection.Added).To(gomega.Equal(4))
 	g.Expect(collection.Updated).To(gomega.Equal(0))
@@ -93,6 +95,7 @@ func TestCollection(t *testing.T) {
 		model.ListOptions{
 			Detail: model.MaxDetail,
 		})
+	g.Expect(err).To(gomega.BeNil())
 	desired[6].Name = "Larry"
 	desired[8].Age = 100
 	tx, _ = DB.Begin()
@@ -104,6 +107,7 @@ func TestCollection(t *testing.T) {
 		Tx:     tx,
 	}
 	err = collection.Update(asIter(desired))
+	g.Expect(err).To(gomega.BeNil())
 	_ = tx.Commit()
 	g.Expect(collection.Added).To(gomega.Equal(0))
 	g.Expect(collection.Updated).To(gomega.Equal(2))
@@ -124,6 +128,7 @@ func TestCollection(t *testing.T) {
 		model.ListOptions{
 			Detail: model.MaxDetail,
 		})
+	g.Expect(err).To(gomega.BeNil())
 	desired = desired[2:]
 	tx, _ = DB.Begin()
 	defer func() {
@@ -134,6 +139,7 @@ func TestCollection(t *testing.T) {
 		Tx:     tx,
 	}
 	err = collection.Delete(asIter(desired))
+	g.Expect(err).To(gomega.BeNil())
 	_ = tx.Commit()
 	g.Expect(collection.Added).To(gomega.Equal(0))
 	g.Expect(collection.Updated).To(gomega.Equal(0))
@@ -151,6 +157,7 @@ func TestCollection(t *testing.T) {
 		model.ListOptions{
 			Detail: model.MaxDetail,
 		})
+	g.Expect(err).To(gomega.BeNil())
 	// delete
 	desired = desired[2:]
 	// update
@@ -174,10 +181,97 @@ func TestCollection(t *testing.T) {
 		Tx:     tx,
 	}
 	err = collection.Reconcile(asIter(desired))
+	g.Expect(err).To(gomega.BeNil())
 	_ = tx.Commit()
 	g.Expect(collection.Added).To(gomega.Equal(5))
 	g.Expect(collection.Updated).To(gomega.Equal(2))
 	g.Expect(collection.Deleted).To(gomega.Equal(2))
+
+	// Test reconcile (disk-backed).
+	stored, err = DB.Find(
+		&TestObject2{},
+		model.ListOptions{
+			Detail: model.MaxDetail,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	// delete
+	desired = desired[1:]
+	// update
+	desired[0].Name = "Elmer"
+	// add
+	desired = append(
+		desired, TestObject2{
+			ID:   20,
+			Name: "20",
+			Age:  20,
+		})
+	tx, _ = DB.Begin()
+	defer func() {
+		_ = tx.End()
+	}()
+	collection = Collection{
+		Stored:     stored,
+		Tx:         tx,
+		DiskBacked: true,
+	}
+	err = collection.Reconcile(asIter(desired))
+	g.Expect(err).To(gomega.BeNil())
+	_ = tx.Commit()
+	g.Expect(collection.Added).To(gomega.Equal(1))
+	g.Expect(collection.Updated).To(gomega.Equal(1))
+	g.Expect(collection.Deleted).To(gomega.Equal(1))
+	updated = &TestObject2{ID: desired[0].ID}
+	err = DB.Get(updated)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(updated.Name).To(gomega.Equal("Elmer"))
+
+	// Test duplicated (desired) Pk; last wins.
+	for _, diskBacked := range []bool{false, true} {
+		stored, err = DB.Find(
+			&TestObject2{},
+			model.ListOptions{
+				Detail: model.MaxDetail,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		duplicated := append([]TestObject2{}, desired...)
+		duplicated = append(
+			duplicated,
+			TestObject2{
+				ID:   desired[1].ID,
+				Name: "Daffy",
+				Age:  desired[1].Age,
+			},
+			TestObject2{
+				ID:   30,
+				Name: "Porky",
+			},
+			TestObject2{
+				ID:   30,
+				Name: "Bugs",
+			})
+		tx, err = DB.Begin()
+		g.Expect(err).To(gomega.BeNil())
+		collection = Collection{
+			Stored:     stored,
+			Tx:         tx,
+			DiskBacked: diskBacked,
+		}
+		err = collection.Reconcile(asIter(duplicated))
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(collection.Added).To(gomega.Equal(1))
+		g.Expect(collection.Updated).To(gomega.Equal(1))
+		g.Expect(collection.Deleted).To(gomega.Equal(0))
+		updated = &TestObject2{ID: desired[1].ID}
+		err = tx.Get(updated)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(updated.Name).To(gomega.Equal("Daffy"))
+		added := &TestObject2{ID: 30}
+		err = tx.Get(added)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(added.Name).To(gomega.Equal("Bugs"))
+		err = tx.End()
+		g.Expect(err).To(gomega.BeNil())
+	}
 }
 
 //
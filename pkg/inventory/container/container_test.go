@@ -0,0 +1,184 @@
+package container
+
+import (
+	"fmt"
+	"github.com/konveyor/controller/pkg/inventory/model"
+	"github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"testing"
+)
+
+type TestObject3 struct {
+	ID   int    `sql:"pk"`
+	Name string `sql:""`
+}
+
+func (r *TestObject3) Pk() string {
+	return fmt.Sprintf("%d", r.ID)
+}
+
+//
+// Test collector.
+type TestCollector struct {
+	owner *core.Pod
+	db    model.DB
+}
+
+func (r *TestCollector) Name() string {
+	return r.owner.Name
+}
+
+func (r *TestCollector) Owner() meta.Object {
+	return r.owner
+}
+
+func (r *TestCollector) Start() error {
+	return nil
+}
+
+func (r *TestCollector) Shutdown() {
+}
+
+func (r *TestCollector) HasParity() bool {
+	return true
+}
+
+func (r *TestCollector) DB() model.DB {
+	return r.db
+}
+
+func (r *TestCollector) Test() error {
+	return nil
+}
+
+func (r *TestCollector) Reset() {
+}
+
+func TestFederated(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	cnt := New()
+	// Collector with a different schema.
+	dbX := model.New("/tmp/test-federated-x.db", &TestObject2{})
+	err = dbX.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = dbX.Close(true)
+	}()
+	err = cnt.Add(
+		&TestCollector{
+			owner: &core.Pod{
+				ObjectMeta: meta.ObjectMeta{
+					Name: "x",
+					UID:  types.UID("x"),
+				},
+			},
+			db: dbX,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	// Collectors (A: even, B: odd).
+	for _, name := range []string{"a", "b"} {
+		db := model.New(
+			"/tmp/test-federated-"+name+".db",
+			&TestObject3{})
+		err = db.Open(true)
+		g.Expect(err).To(gomega.BeNil())
+		defer func(db model.DB) {
+			_ = db.Close(true)
+		}(db)
+		for i := 0; i < 10; i++ {
+			if (i%2 == 0) != (name == "a") {
+				continue
+			}
+			err = db.Insert(
+				&TestObject3{
+					ID:   i,
+					Name: fmt.Sprintf("n%d", 9-i),
+				})
+			g.Expect(err).To(gomega.BeNil())
+		}
+		err = cnt.Add(
+			&TestCollector{
+				owner: &core.Pod{
+					ObjectMeta: meta.ObjectMeta{
+						Name: name,
+						UID:  types.UID(name),
+					},
+				},
+				db: db,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Count.
+	n, err := cnt.FederatedCount(&TestObject3{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(10)))
+	n, err = cnt.FederatedCount(&TestObject3{}, model.Gt("ID", 6))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+	// List sorted by ID.
+	list, err := cnt.FederatedList(
+		&TestObject3{},
+		model.ListOptions{
+			Sort: []int{1},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(10))
+	for i, m := range list {
+		g.Expect(m.Model.(*TestObject3).ID).To(gomega.Equal(i))
+		if i%2 == 0 {
+			g.Expect(string(m.Key.UID)).To(gomega.Equal("a"))
+		} else {
+			g.Expect(string(m.Key.UID)).To(gomega.Equal("b"))
+		}
+	}
+	// List sorted by name; paginated.
+	list, err = cnt.FederatedList(
+		&TestObject3{},
+		model.ListOptions{
+			Detail: model.MaxDetail,
+			Sort:   []int{2},
+			Page: &model.Page{
+				Offset: 2,
+				Limit:  3,
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	g.Expect(list[0].Model.(*TestObject3).ID).To(gomega.Equal(7))
+	g.Expect(list[1].Model.(*TestObject3).ID).To(gomega.Equal(6))
+	g.Expect(list[2].Model.(*TestObject3).ID).To(gomega.Equal(5))
+	// List with predicate.
+	list, err = cnt.FederatedList(
+		&TestObject3{},
+		model.ListOptions{
+			Predicate: model.Eq("ID", 5),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(string(list[0].Key.UID)).To(gomega.Equal("b"))
+}
+
+func TestCompare(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	pairs := [][2]interface{}{
+		{"a", "b"},
+		{false, true},
+		{int8(-1), int8(1)},
+		{uint(1), uint(2)},
+		{uint64(1), uint64(1 << 40)},
+		{float32(0.5), float32(1.5)},
+		{-0.5, 0.25},
+	}
+	for _, pair := range pairs {
+		a := reflect.ValueOf(pair[0])
+		b := reflect.ValueOf(pair[1])
+		g.Expect(compare(a, b)).To(gomega.Equal(-1))
+		g.Expect(compare(b, a)).To(gomega.Equal(1))
+		g.Expect(compare(a, a)).To(gomega.Equal(0))
+	}
+}
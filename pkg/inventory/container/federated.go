@@ -0,0 +1,299 @@
+package container
+
+import (
+	"errors"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/konveyor/controller/pkg/inventory/model"
+	"github.com/mattn/go-sqlite3"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//
+// Federated query result.
+type Federated struct {
+	// The owning collector key.
+	Key Key
+	// The model.
+	Model model.Model
+}
+
+//
+// Federated list.
+// List models across the DB of every collector. Each DB
+// is queried (fanned out) using the list options and the
+// results are merged. The sort and pagination defined by
+// the options are applied to the merged result.
+// Collectors with a DB not containing the model (table)
+// are skipped.
+func (c *Container) FederatedList(m model.Model, options model.ListOptions) (list []Federated, err error) {
+	list = []Federated{}
+	page := options.Page
+	options.Page = c.fedPage(page)
+	for _, collector := range c.sorted() {
+		var found []Federated
+		found, err = c.fedFind(collector, m, options)
+		if err != nil {
+			return
+		}
+		list = append(list, found...)
+	}
+	if len(options.Sort) > 0 {
+		err = c.fedSort(list, options)
+		if err != nil {
+			return
+		}
+	}
+	if page != nil {
+		page.Slice(&list)
+	}
+
+	log.V(4).Info(
+		"federated list succeeded.",
+		"model",
+		model.Describe(m),
+		"matched",
+		len(list))
+
+	return
+}
+
+//
+// Federated count.
+// Count models across the DB of every collector.
+// Collectors with a DB not containing the model (table)
+// are skipped.
+func (c *Container) FederatedCount(m model.Model, predicate model.Predicate) (n int64, err error) {
+	for _, collector := range c.sorted() {
+		db := collector.DB()
+		if db == nil {
+			continue
+		}
+		count, cErr := db.Count(m, predicate)
+		if cErr != nil {
+			if missingTable(cErr) {
+				continue
+			}
+			err = liberr.Wrap(cErr)
+			return
+		}
+		n += count
+	}
+
+	return
+}
+
+//
+// Find models in the collector DB.
+func (c *Container) fedFind(collector Collector, m model.Model, options model.ListOptions) (list []Federated, err error) {
+	db := collector.DB()
+	if db == nil {
+		return
+	}
+	itr, err := db.Find(m, options)
+	if err != nil {
+		if missingTable(err) {
+			err = nil
+		}
+		return
+	}
+	defer itr.Close()
+	key := c.key(collector.Owner())
+	for {
+		object, hasNext := itr.Next()
+		if !hasNext {
+			break
+		}
+		list = append(
+			list,
+			Federated{
+				Key:   key,
+				Model: object.(model.Model),
+			})
+	}
+
+	return
+}
+
+//
+// Build the page used to query each DB.
+// The merged result must contain the models within
+// the requested page so each DB is queried for the
+// first (offset + limit) models.
+func (c *Container) fedPage(page *model.Page) *model.Page {
+	if page == nil {
+		return nil
+	}
+	maxInt := int(^uint(0) >> 1)
+	if page.Limit > maxInt-page.Offset {
+		return nil
+	}
+
+	return &model.Page{
+		Limit: page.Offset + page.Limit,
+	}
+}
+
+//
+// Collectors sorted by key.
+// Provides a stable order for merged results.
+func (c *Container) sorted() (list []Collector) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	keys := []Key{}
+	for key := range c.content {
+		keys = append(keys, key)
+	}
+	sort.Slice(
+		keys,
+		func(i, j int) bool {
+			if keys[i].Kind != keys[j].Kind {
+				return keys[i].Kind < keys[j].Kind
+			}
+			return keys[i].UID < keys[j].UID
+		})
+	for _, key := range keys {
+		list = append(list, c.content[key])
+	}
+
+	return
+}
+
+//
+// Sort the merged list.
+// The sort criteria is the (1-based) position of fields
+// in the detail level (select) list as with ORDER BY.
+// The sort keys are extracted once for each model.
+func (c *Container) fedSort(list []Federated, options model.ListOptions) (err error) {
+	type row struct {
+		Federated
+		keys []reflect.Value
+	}
+	rows := make([]row, 0, len(list))
+	for _, fed := range list {
+		var keys []reflect.Value
+		keys, err = c.fedKeys(fed.Model, options)
+		if err != nil {
+			return
+		}
+		rows = append(
+			rows,
+			row{
+				Federated: fed,
+				keys:      keys,
+			})
+	}
+	sort.SliceStable(
+		rows,
+		func(i, j int) bool {
+			for n := range options.Sort {
+				a := rows[i].keys[n]
+				b := rows[j].keys[n]
+				if !a.IsValid() || !b.IsValid() {
+					continue
+				}
+				switch compare(a, b) {
+				case -1:
+					return true
+				case 1:
+					return false
+				}
+			}
+			return false
+		})
+	for i := range rows {
+		list[i] = rows[i].Federated
+	}
+
+	return
+}
+
+//
+// Extract the sort keys.
+// Keys are invalid when the position is not within
+// the detail level.
+func (c *Container) fedKeys(m model.Model, options model.ListOptions) (keys []reflect.Value, err error) {
+	md, err := model.Inspect(m)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	fields := []*model.Field{}
+	for _, f := range md.Fields {
+		if f.MatchDetail(options.Detail) {
+			fields = append(fields, f)
+		}
+	}
+	keys = make([]reflect.Value, len(options.Sort))
+	for n, position := range options.Sort {
+		if position > 0 && position <= len(fields) {
+			keys[n] = *fields[position-1].Value
+		}
+	}
+
+	return
+}
+
+//
+// Compare field values.
+// Returns: -1 (a<b), 0 (a=b), 1 (a>b).
+func compare(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case b.Bool():
+			return -1
+		default:
+			return 1
+		}
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		switch {
+		case a.Int() < b.Int():
+			return -1
+		case a.Int() > b.Int():
+			return 1
+		}
+	case reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	case reflect.Float32,
+		reflect.Float64:
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		}
+	}
+
+	return 0
+}
+
+//
+// The error reports the model (table) not found in the DB.
+func missingTable(err error) bool {
+	sql3Err := sqlite3.Error{}
+	if errors.As(err, &sql3Err) {
+		return sql3Err.Code == sqlite3.ErrError &&
+			strings.HasPrefix(sql3Err.Error(), "no such table")
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"errors"
+	fb "github.com/konveyor/controller/pkg/filebacked"
+	"github.com/konveyor/controller/pkg/ref"
+	"time"
+)
+
+//
+// Watch (event) queue size.
+// The number of transactions queued per watch.
+var WatchQueueSize = 250
+
+//
+// The default time the committer is blocked when the
+// watch queue is full and the BlockPolicy is used.
+var WatchBlockTimeout = time.Second * 10
+
+//
+// Errors.
+var (
+	// Watch terminated by backpressure.
+	BackpressureErr = errors.New("watch queue full, terminated, resync")
+)
+
+//
+// Backpressure policy.
+// Determines how events are handled when the watch
+// queue is full.
+type Backpressure uint8
+
+//
+// Backpressure policies.
+const (
+	// Transactions are discarded and Handler.Error() is called.
+	DropPolicy Backpressure = iota
+	// The committer is blocked until the event is queued or
+	// the timeout has expired. When expired, the watch is
+	// terminated.
+	BlockPolicy
+	// Transactions are spilled to an unbounded file-backed
+	// queue and delivered in order.
+	SpillPolicy
+	// Events are merged into the latest state per model (PK)
+	// and delivered in order of first occurrence.
+	CoalescePolicy
+	// The watch is terminated with BackpressureErr. The
+	// watcher must resync.
+	TerminatePolicy
+)
+
+//
+// Watch statistics.
+type WatchStats struct {
+	// Transactions queued.
+	Queued int
+	// Events pending in the (spill|coalesce) overflow.
+	Overflow int
+	// Events delivered to the handler.
+	Delivered uint64
+	// Transactions dropped.
+	Dropped uint64
+	// Events merged by coalescing.
+	Coalesced uint64
+	// Total time the committer was blocked.
+	Blocked time.Duration
+	// Handler panics recovered.
+	Panics uint64
+}
+
+//
+// Overflow (event) queue.
+// Used by the SpillPolicy and CoalescePolicy when the
+// watch queue is full.
+type overflow struct {
+	// Policy.
+	policy Backpressure
+	// Spilled events.
+	spill *fb.List
+	// Coalesced events (ordered).
+	events []*Event
+	// Coalesced events index by key.
+	index map[string]int
+	// Number of events.
+	count int
+	// Number of events merged.
+	merged uint64
+}
+
+//
+// Add the events.
+func (r *overflow) add(itr fb.Iterator) {
+	defer itr.Close()
+	for {
+		event := Event{}
+		if !event.next(itr) {
+			break
+		}
+		if r.policy == CoalescePolicy {
+			r.coalesce(&event)
+			continue
+		}
+		if r.spill == nil {
+			r.spill = fb.NewList()
+		}
+		event.append(r.spill)
+		r.count++
+	}
+}
+
+//
+// Merge the event with the pending event for the same model.
+func (r *overflow) coalesce(event *Event) {
+	if r.index == nil {
+		r.index = make(map[string]int)
+	}
+	key := ref.ToKind(event.Model) + "/" + event.Model.Pk()
+	i, found := r.index[key]
+	if !found {
+		r.index[key] = len(r.events)
+		r.events = append(r.events, event)
+		r.count++
+		return
+	}
+	r.merged++
+	if r.events[i].merge(event) {
+		return
+	}
+	r.events[i] = nil
+	delete(r.index, key)
+	r.count--
+}
+
+//
+// Number of events.
+func (r *overflow) Len() int {
+	return r.count
+}
+
+//
+// Take (all) the events.
+// The overflow is reset.
+func (r *overflow) take() (itr fb.Iterator) {
+	if r.spill != nil {
+		itr = r.spill.Iter()
+		r.spill.Close()
+	} else {
+		list := fb.NewList()
+		for _, event := range r.events {
+			if event != nil {
+				event.append(list)
+			}
+		}
+		itr = list.Iter()
+		list.Close()
+	}
+
+	r.reset()
+
+	return
+}
+
+//
+// Reset (discard) the events.
+func (r *overflow) reset() {
+	if r.spill != nil {
+		r.spill.Close()
+		r.spill = nil
+	}
+	r.events = nil
+	r.index = nil
+	r.count = 0
+}
+
+//
+// Merge the next event for the same model.
+// The net event replaces this event. Returns false
+// when the events cancel out.
+//   Created, Updated => Created (updated model).
+//   Created, Deleted => (none).
+//   Updated, Updated => Updated (original model).
+//   Updated, Deleted => Deleted.
+//   Deleted, Created => Updated.
+func (r *Event) merge(next *Event) (keep bool) {
+	keep = true
+	switch r.Action {
+	case Created:
+		switch next.Action {
+		case Updated:
+			r.Model = next.Updated
+		case Deleted:
+			keep = false
+		default:
+			r.Model = next.Model
+		}
+	case Updated:
+		switch next.Action {
+		case Updated:
+			r.Updated = next.Updated
+		default:
+			r.Action = next.Action
+			r.Model = next.Model
+			r.Updated = nil
+			r.PriorLabels = nil
+		}
+	case Deleted:
+		switch next.Action {
+		case Created:
+			r.Action = Updated
+			r.Updated = next.Model
+			r.PriorLabels = r.ModelLabels
+		default:
+			r.Action = next.Action
+			r.Model = next.Model
+			r.Updated = next.Updated
+			r.PriorLabels = next.PriorLabels
+		}
+	}
+	r.ID = next.ID
+	r.Labels = next.Labels
+	r.ModelLabels = next.ModelLabels
+
+	return
+}
@@ -0,0 +1,623 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/konveyor/controller/pkg/logging"
+	"github.com/konveyor/controller/pkg/ref"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Change-data-capture (CDC) record.
+// Exported (NDJSON) representation of an event.
+type Record struct {
+	// Event ID.
+	ID uint64 `json:"id"`
+	// Timestamp.
+	Time time.Time `json:"time"`
+	// Action (created|updated|deleted).
+	Action string `json:"action"`
+	// Model kind.
+	Kind string `json:"kind"`
+	// Transaction labels.
+	Labels []string `json:"labels,omitempty"`
+	// The event subject.
+	Model Model `json:"model"`
+	// The updated model.
+	Updated Model `json:"updated,omitempty"`
+}
+
+//
+// Errors.
+var (
+	// Outbox not enabled.
+	ExportErr = errors.New("export requires the outbox (Settings.Export) enabled")
+	// Checkpoint before the trimmed outbox.
+	ExportGapErr = errors.New("export checkpoint is before events trimmed from the outbox")
+)
+
+//
+// Exporter defaults.
+const (
+	// The max number of records read from the outbox
+	// and written to the sink per batch.
+	DefaultExportBatch = 100
+	// The interval at which a failed export is retried.
+	DefaultExportRetry = time.Second * 10
+)
+
+//
+// Export sink.
+type Sink interface {
+	// Write records.
+	// Records must be durable when nil is returned.
+	Write([]Record) error
+	// Close the sink.
+	Close() error
+}
+
+//
+// CDC outbox entry.
+// When enabled (Settings.Export), events are recorded in the
+// outbox within the transaction that made the change. Entries
+// are deleted once exported by all exporters (see: ExportMark).
+// The models are encoded field by field as stored in the model
+// table so encrypted and compressed fields are stored encrypted
+// and compressed.
+type Outbox struct {
+	// Event ID.
+	ID int64 `sql:"pk"`
+	// Timestamp.
+	Time time.Time `sql:""`
+	// Action (created|updated|deleted).
+	Action string `sql:""`
+	// Model kind.
+	Kind string `sql:""`
+	// Transaction labels.
+	Labels []string `sql:""`
+	// The encoded event subject.
+	Model string `sql:""`
+	// The encoded updated model.
+	Updated string `sql:""`
+}
+
+//
+// Get the primary key.
+func (r *Outbox) Pk() string {
+	return strconv.FormatInt(r.ID, 10)
+}
+
+//
+// Populate the entry using the event.
+// Encrypted fields are encrypted using the key provider.
+func (r *Outbox) With(event Event, keys KeyProvider) (err error) {
+	r.ID = int64(event.ID)
+	r.Time = time.Now()
+	r.Action = event.action()
+	r.Kind = ref.ToKind(event.Model)
+	r.Labels = event.Labels
+	r.Model, err = encodeModel(keys, event.Model, r.aad("Model"))
+	if err != nil {
+		return
+	}
+	if event.Action == Updated {
+		r.Updated, err = encodeModel(keys, event.Updated, r.aad("Updated"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Build the record.
+// The models are decoded using the data model definitions.
+// Encrypted fields are decrypted using the key provider.
+func (r *Outbox) record(dm *DataModel, keys KeyProvider) (record Record, err error) {
+	md, found := dm.Find(r.Kind)
+	if !found {
+		err = liberr.New("kind not found.", "kind", r.Kind)
+		return
+	}
+	record = Record{
+		ID:     uint64(r.ID),
+		Time:   r.Time,
+		Action: r.Action,
+		Kind:   r.Kind,
+		Labels: r.Labels,
+	}
+	record.Model, err = decodeModel(md, keys, r.Model, r.aad("Model"))
+	if err != nil {
+		return
+	}
+	if r.Updated != "" {
+		record.Updated, err = decodeModel(md, keys, r.Updated, r.aad("Updated"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// AAD used to encrypt the model fields.
+// Bound to the entry, the role (Model|Updated) and the field.
+func (r *Outbox) aad(role string) func(*Field) string {
+	return func(f *Field) string {
+		return aad("Outbox", r.Pk(), r.Kind+"."+role+"."+f.Name)
+	}
+}
+
+//
+// Encode the model.
+// The fields are (json) encoded by name. Encrypted and
+// compressed fields are encoded as stored in the model
+// table using the AAD returned by `aad`.
+func encodeModel(keys KeyProvider, m Model, aad func(*Field) string) (encoded string, err error) {
+	md, err := Inspect(m)
+	if err != nil {
+		return
+	}
+	fields := map[string]interface{}{}
+	for _, f := range md.Fields {
+		if f.Encrypted() || f.Compressed() {
+			f.pull()
+			var value interface{}
+			value, err = f.encode(keys, aad(f), f.string)
+			if err != nil {
+				return
+			}
+			fields[f.Name] = value
+		} else {
+			fields[f.Name] = f.Value.Interface()
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		err = liberr.Wrap(err, "kind", md.Kind)
+		return
+	}
+
+	encoded = string(b)
+
+	return
+}
+
+//
+// Decode the model.
+// See: encodeModel().
+func decodeModel(md *Definition, keys KeyProvider, encoded string, aad func(*Field) string) (m Model, err error) {
+	m = md.NewModel().(Model)
+	mDef, err := Inspect(m)
+	if err != nil {
+		return
+	}
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal([]byte(encoded), &fields)
+	if err != nil {
+		err = liberr.Wrap(err, "kind", md.Kind)
+		return
+	}
+	for _, f := range mDef.Fields {
+		raw, found := fields[f.Name]
+		if !found {
+			continue
+		}
+		if f.Encrypted() || f.Compressed() {
+			b := []byte{}
+			err = json.Unmarshal(raw, &b)
+			if err == nil {
+				f.raw = b
+				err = f.Push(keys, aad(f))
+			}
+		} else {
+			err = json.Unmarshal(raw, f.Value.Addr().Interface())
+		}
+		if err != nil {
+			err = liberr.Wrap(err, "kind", md.Kind, "field", f.Name)
+			return
+		}
+	}
+
+	return
+}
+
+//
+// CDC export mark.
+// The (durable) checkpoint of each exporter keyed by the
+// checkpoint path. The outbox is trimmed to the lowest
+// checkpoint of all exporters ever registered, so events
+// are retained for exporters not currently registered. The
+// trimmed mark (Checkpoint="") is the highest event ID deleted
+// from the outbox. Registering an exporter with a checkpoint
+// before the trimmed mark fails with ExportGapErr.
+type ExportMark struct {
+	// Checkpoint path.
+	Checkpoint string `sql:"pk"`
+	// Last exported event ID.
+	Last int64 `sql:""`
+}
+
+//
+// Get the primary key.
+func (r *ExportMark) Pk() string {
+	return r.Checkpoint
+}
+
+//
+// CDC exporter.
+// Events recorded in the (persisted) outbox are exported to the
+// sink by a background goroutine in batches. The ID of the last
+// exported event is recorded in the checkpoint file (and the DB
+// export mark) and the outbox is drained from the checkpoint so
+// events committed while no exporter was registered (or not
+// written because of sink errors) are exported without gaps. On
+// start, the event serial number is advanced past the checkpoint
+// so IDs continue to increase across restarts.
+type Exporter struct {
+	// Sink.
+	Sink Sink
+	// Checkpoint file path.
+	Checkpoint string
+	// Max number of records written per batch.
+	// Default: DefaultExportBatch.
+	Batch int
+	// Interval at which a failed export is retried.
+	// Default: DefaultExportRetry.
+	Retry time.Duration
+	// DB client.
+	client *Client
+	// Mutex.
+	mutex sync.Mutex
+	// Last exported event ID.
+	last uint64
+	// Events committed.
+	signal chan struct{}
+	// Stop requested.
+	stop chan struct{}
+	// Stopped.
+	wg sync.WaitGroup
+	// Logger.
+	log logr.Logger
+}
+
+//
+// Last exported event ID.
+func (r *Exporter) Last() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last
+}
+
+//
+// Load the checkpoint and seed the serial number.
+func (r *Exporter) load() (err error) {
+	r.log = logging.WithName("journal|cdc").WithValues(
+		"checkpoint",
+		r.Checkpoint)
+	b, err := ioutil.ReadFile(r.Checkpoint)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = liberr.Wrap(err, "path", r.Checkpoint)
+			return
+		}
+		err = nil
+	}
+	s := strings.TrimSpace(string(b))
+	if s != "" {
+		r.last, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			err = liberr.Wrap(err, "path", r.Checkpoint)
+			return
+		}
+	}
+	serial.seed(1, r.last)
+
+	return
+}
+
+//
+// Start the export goroutine.
+func (r *Exporter) start() {
+	r.signal = make(chan struct{}, 1)
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go r.run()
+
+	r.log.V(3).Info(
+		"cdc: started.",
+		"last",
+		r.last)
+}
+
+//
+// Export the outbox.
+// Drained when started, when events are committed
+// and (retried) after a failed export.
+func (r *Exporter) run() {
+	defer r.wg.Done()
+	for {
+		var wait <-chan time.Time
+		err := r.drain()
+		if err != nil {
+			r.log.Error(err, "cdc: export failed.")
+			wait = time.After(r.retry())
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-r.signal:
+		case <-wait:
+		}
+	}
+}
+
+//
+// Notify the exporter that events have been committed.
+// Never blocks.
+func (r *Exporter) notify() {
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+//
+// Drain the outbox.
+// Records after the checkpoint are read and written
+// to the sink in batches.
+func (r *Exporter) drain() (err error) {
+	batch := r.batch()
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		var records []Record
+		records, err = r.client.outbox(r.Last(), batch)
+		if err != nil || len(records) == 0 {
+			return
+		}
+		err = r.Sink.Write(records)
+		if err != nil {
+			err = liberr.Wrap(err, "pending", len(records))
+			return
+		}
+		last := records[len(records)-1].ID
+		err = r.checkpoint(last)
+		if err != nil {
+			r.log.Error(err, "cdc: checkpoint failed.")
+		}
+		r.mutex.Lock()
+		r.last = last
+		r.mutex.Unlock()
+		err = r.client.exportMark(r.Checkpoint, last)
+		if err != nil {
+			return
+		}
+		err = r.client.trimOutbox()
+		if err != nil {
+			return
+		}
+
+		r.log.V(5).Info(
+			"cdc: exported.",
+			"last",
+			last)
+
+		if len(records) < batch {
+			return
+		}
+	}
+}
+
+//
+// The batch size.
+// Batch <= 0 = DefaultExportBatch.
+func (r *Exporter) batch() (n int) {
+	n = r.Batch
+	if n <= 0 {
+		n = DefaultExportBatch
+	}
+
+	return
+}
+
+//
+// The retry interval.
+// Retry <= 0 = DefaultExportRetry.
+func (r *Exporter) retry() (d time.Duration) {
+	d = r.Retry
+	if d <= 0 {
+		d = DefaultExportRetry
+	}
+
+	return
+}
+
+//
+// Write the checkpoint.
+// Written to a temporary file then renamed.
+func (r *Exporter) checkpoint(id uint64) (err error) {
+	tmp := r.Checkpoint + ".tmp"
+	fp, err := os.Create(tmp)
+	if err != nil {
+		err = liberr.Wrap(err, "path", tmp)
+		return
+	}
+	_, err = fmt.Fprintf(fp, "%d\n", id)
+	if err == nil {
+		err = fp.Sync()
+	}
+	_ = fp.Close()
+	if err != nil {
+		err = liberr.Wrap(err, "path", tmp)
+		return
+	}
+	err = os.Rename(tmp, r.Checkpoint)
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Checkpoint)
+		return
+	}
+
+	return
+}
+
+//
+// Close the exporter.
+// The export goroutine is stopped and the sink is closed.
+func (r *Exporter) close() {
+	if r.stop != nil {
+		close(r.stop)
+		r.wg.Wait()
+		r.stop = nil
+	}
+	err := r.Sink.Close()
+	if err != nil {
+		r.log.Error(err, "cdc: close sink failed.")
+	}
+}
+
+//
+// Rotating (local) file sink.
+// Records are written as NDJSON. When the file exceeds
+// MaxSize, it is rotated: <path>.1 ... <path>.<MaxFiles>.
+type FileSink struct {
+	// File path.
+	Path string
+	// Max file size (bytes). 0 = never rotated.
+	MaxSize int64
+	// Max rotated files retained.
+	MaxFiles int
+	// File.
+	file *os.File
+	// Current size.
+	size int64
+}
+
+//
+// Write records.
+func (r *FileSink) Write(records []Record) (err error) {
+	if r.file == nil {
+		err = r.open()
+		if err != nil {
+			return
+		}
+	}
+	writer := bufio.NewWriter(r.file)
+	for i := range records {
+		var b []byte
+		b, err = json.Marshal(&records[i])
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		b = append(b, '\n')
+		_, err = writer.Write(b)
+		if err != nil {
+			err = liberr.Wrap(err, "path", r.Path)
+			return
+		}
+		r.size += int64(len(b))
+	}
+	err = writer.Flush()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	err = r.file.Sync()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	if r.MaxSize > 0 && r.size >= r.MaxSize {
+		err = r.rotate()
+	}
+
+	return
+}
+
+//
+// Close the file.
+func (r *FileSink) Close() (err error) {
+	if r.file == nil {
+		return
+	}
+	err = r.file.Close()
+	r.file = nil
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+	}
+
+	return
+}
+
+//
+// Open the file for append.
+func (r *FileSink) open() (err error) {
+	err = os.MkdirAll(filepath.Dir(r.Path), 0755)
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	r.file, err = os.OpenFile(
+		r.Path,
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0644)
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	st, err := r.file.Stat()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+
+	r.size = st.Size()
+
+	return
+}
+
+//
+// Rotate the file.
+func (r *FileSink) rotate() (err error) {
+	err = r.Close()
+	if err != nil {
+		return
+	}
+	name := func(n int) string {
+		return fmt.Sprintf("%s.%d", r.Path, n)
+	}
+	if r.MaxFiles > 0 {
+		_ = os.Remove(name(r.MaxFiles))
+		for n := r.MaxFiles - 1; n > 0; n-- {
+			_ = os.Rename(name(n), name(n+1))
+		}
+		err = os.Rename(r.Path, name(1))
+	} else {
+		err = os.Remove(r.Path)
+	}
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+
+	r.size = 0
+
+	return
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/go-logr/logr"
 	liberr "github.com/konveyor/controller/pkg/error"
 	fb "github.com/konveyor/controller/pkg/filebacked"
+	"github.com/konveyor/controller/pkg/ref"
 	"os"
+	"regexp"
 	"time"
 )
 
@@ -25,6 +27,12 @@ type DB interface {
 	List(interface{}, ListOptions) error
 	// Find models.
 	Find(interface{}, ListOptions) (fb.Iterator, error)
+	// Stream models.
+	Stream(interface{}, ListOptions) (*Cursor, error)
+	// Distinct values of a field with counts.
+	Distinct(Model, string, ListOptions) ([]Facet, error)
+	// Distinct values of each field with counts.
+	Facets(Model, []string, ListOptions) (map[string][]Facet, error)
 	// Count based on the specified model.
 	Count(Model, Predicate) (int64, error)
 	// Begin a transaction.
@@ -37,10 +45,22 @@ type DB interface {
 	Update(Model, ...Predicate) error
 	// Delete a model.
 	Delete(Model) error
+	// Re-encrypt encrypted fields using the current key.
+	Reencrypt() (int64, error)
+	// Compress (migrate) compressed fields.
+	Compress() (int64, error)
 	// Watch a model collection.
 	Watch(Model, EventHandler) (*Watch, error)
 	// End a watch.
 	EndWatch(watch *Watch)
+	// Status of watches.
+	WatchStatus() []WatchStatus
+	// Export (CDC) committed events.
+	Export(*Exporter) error
+	// End an export.
+	EndExport(*Exporter)
+	// Register a trigger.
+	Trigger(Model, uint8, TriggerFn)
 }
 
 //
@@ -56,17 +76,42 @@ type Client struct {
 	pool Pool
 	// Journal
 	journal Journal
+	// TTL reaper.
+	reaper Reaper
+	// Triggers.
+	triggers Triggers
+	// Settings.
+	settings Settings
 	// Logger
 	log logr.Logger
 }
 
+//
+// Database settings.
+type Settings struct {
+	// Key provider used to encrypt/decrypt `encrypt` fields.
+	// Required when any model has an encrypted field.
+	Keys KeyProvider
+	// The interval at which expired (ttl) models are reaped.
+	// Default: DefaultReapInterval.
+	ReapInterval time.Duration
+	// The max number of expired models deleted per
+	// transaction when reaped. Default: DefaultReapBatch.
+	ReapBatch int
+	// Record events in the (CDC) outbox.
+	// Required by Export().
+	Export bool
+}
+
 //
 // Create the database.
-// Build the schema to support the specified models.
+// Build the schema to support the specified models,
+// open the (journal) event log and start the (TTL) reaper.
 // See: Pool.Open().
 func (r *Client) Open(delete bool) (err error) {
 	if delete {
 		_ = os.Remove(r.path)
+		_ = os.RemoveAll(r.eventDir())
 		r.log.V(3).Info("DB file deleted.")
 	}
 	err = r.pool.Open(1, 10, r.path, &r.journal)
@@ -84,6 +129,15 @@ func (r *Client) Open(delete bool) (err error) {
 	if err != nil {
 		panic(err)
 	}
+	err = r.journal.open(r.eventDir(), r.dm, r.settings.Keys)
+	if err != nil {
+		return
+	}
+	r.reaper = Reaper{
+		client: r,
+		log:    r.log,
+	}
+	r.reaper.Start()
 
 	r.log.V(3).Info("session pool opened.")
 
@@ -92,8 +146,10 @@ func (r *Client) Open(delete bool) (err error) {
 
 //
 // Close the database.
-// The session pool and journal are closed.
+// The reaper is stopped and the session pool and
+// journal are closed.
 func (r *Client) Close(delete bool) (err error) {
+	r.reaper.Stop()
 	jErr := r.journal.Close()
 	if jErr != nil {
 		r.log.Error(
@@ -108,6 +164,7 @@ func (r *Client) Close(delete bool) (err error) {
 	}
 	if delete {
 		_ = os.Remove(r.path)
+		_ = os.RemoveAll(r.eventDir())
 		r.log.V(3).Info("DB file deleted.")
 	}
 
@@ -116,6 +173,12 @@ func (r *Client) Close(delete bool) (err error) {
 	return
 }
 
+//
+// The (journal) event log directory.
+func (r *Client) eventDir() string {
+	return r.path + ".events"
+}
+
 //
 // Execute SQL.
 // Delegated to Tx.Execute().
@@ -134,7 +197,7 @@ func (r *Client) Get(model Model) (err error) {
 	session := r.pool.Reader()
 	defer session.Return()
 	mark := time.Now()
-	err = Table{session.db}.Get(model)
+	err = r.table(session.db).Get(model)
 	if err == nil {
 		r.log.V(4).Info(
 			"get succeeded.",
@@ -154,7 +217,7 @@ func (r *Client) List(list interface{}, options ListOptions) (err error) {
 	session := r.pool.Reader()
 	defer session.Return()
 	mark := time.Now()
-	err = Table{session.db}.List(list, options)
+	err = r.table(session.db).List(list, options)
 	if err == nil {
 		r.log.V(4).Info(
 			"list succeeded.",
@@ -173,7 +236,7 @@ func (r *Client) Find(model interface{}, options ListOptions) (itr fb.Iterator,
 	session := r.pool.Reader()
 	defer session.Return()
 	mark := time.Now()
-	itr, err = Table{session.db}.Find(model, options)
+	itr, err = r.table(session.db).Find(model, options)
 	if err == nil {
 		r.log.V(4).Info(
 			"list succeeded.",
@@ -186,13 +249,88 @@ func (r *Client) Find(model interface{}, options ListOptions) (itr fb.Iterator,
 	return
 }
 
+//
+// Stream models.
+// A reader session is reserved until the cursor is closed.
+func (r *Client) Stream(model interface{}, options ListOptions) (cursor *Cursor, err error) {
+	session := r.pool.Reader()
+	mark := time.Now()
+	cursor, err = r.table(session.db).Stream(model, options)
+	if err != nil {
+		session.Return()
+		return
+	}
+	cursor.session = session
+
+	r.log.V(4).Info(
+		"stream succeeded.",
+		"options",
+		options,
+		"duration",
+		time.Since(mark))
+
+	return
+}
+
+//
+// Distinct values of a field with the number of models.
+// See: Table.Distinct().
+func (r *Client) Distinct(model Model, field string, options ListOptions) (list []Facet, err error) {
+	session := r.pool.Reader()
+	defer session.Return()
+	mark := time.Now()
+	list, err = r.table(session.db).Distinct(model, field, options)
+	if err == nil {
+		r.log.V(4).Info(
+			"distinct succeeded.",
+			"field",
+			field,
+			"options",
+			options,
+			"duration",
+			time.Since(mark))
+	}
+
+	return
+}
+
+//
+// Distinct values of each field with the number of models.
+// Returns: facets keyed by field.
+// See: Table.Distinct().
+func (r *Client) Facets(model Model, fields []string, options ListOptions) (facets map[string][]Facet, err error) {
+	session := r.pool.Reader()
+	defer session.Return()
+	mark := time.Now()
+	facets = map[string][]Facet{}
+	for _, field := range fields {
+		var list []Facet
+		list, err = r.table(session.db).Distinct(model, field, options)
+		if err != nil {
+			return
+		}
+		facets[field] = list
+	}
+
+	r.log.V(4).Info(
+		"facets succeeded.",
+		"fields",
+		fields,
+		"options",
+		options,
+		"duration",
+		time.Since(mark))
+
+	return
+}
+
 //
 // Count models.
 func (r *Client) Count(model Model, predicate Predicate) (n int64, err error) {
 	session := r.pool.Reader()
 	defer session.Return()
 	mark := time.Now()
-	n, err = Table{session.db}.Count(model, predicate)
+	n, err = r.table(session.db).Count(model, predicate)
 	if err == nil {
 		r.log.V(4).Info(
 			"count succeeded.",
@@ -221,6 +359,8 @@ func (r *Client) Begin(labels ...string) (tx *Tx, error error) {
 	tx = &Tx{
 		session: session,
 		real:    realTx,
+		keys:    r.settings.Keys,
+		export:  r.settings.Export,
 		journal: &r.journal,
 		staged:  fb.NewList(),
 		dm:      r.dm,
@@ -228,9 +368,10 @@ func (r *Client) Begin(labels ...string) (tx *Tx, error error) {
 			tx:  realTx,
 			log: r.log,
 		},
-		started: time.Now(),
-		labels:  labels,
-		log:     r.log,
+		started:  time.Now(),
+		labels:   labels,
+		triggers: &r.triggers,
+		log:      r.log,
 	}
 
 	r.log.V(4).Info("tx begin.", "duration", time.Since(mark))
@@ -316,10 +457,182 @@ func (r *Client) Delete(model Model) (err error) {
 	return
 }
 
+//
+// Table using the DB connection.
+func (r *Client) table(db DBTX) Table {
+	return Table{
+		DB:   db,
+		Keys: r.settings.Keys,
+	}
+}
+
+//
+// Re-encrypt encrypted fields.
+// Stored values not encrypted using the current key are
+// (re)encrypted using the current key. Intended to be called
+// after the key has been rotated. Events are not reported.
+// Returns: the number of models updated.
+func (r *Client) Reencrypt() (n int64, err error) {
+	mark := time.Now()
+	encrypted := func(f *Field) bool {
+		return f.Encrypted()
+	}
+	if !r.selected(encrypted) {
+		return
+	}
+	keys := r.settings.Keys
+	if keys == nil {
+		err = liberr.Wrap(KeyProviderErr)
+		return
+	}
+	current, _, err := keys.Current()
+	if err != nil {
+		return
+	}
+	n, err = r.rewrite(
+		encrypted,
+		func(f *Field, stored string, blob bool, aad string) (value interface{}, changed bool, err error) {
+			value = stored
+			if blob {
+				value = []byte(stored)
+				if encryptedWith(stored) == current {
+					return
+				}
+			}
+			plain, _, err := f.decode(keys, aad, stored, blob)
+			if err != nil {
+				return
+			}
+			value, err = f.encode(keys, aad, plain)
+			if err != nil {
+				return
+			}
+			changed = true
+			return
+		})
+	if err == nil {
+		r.log.V(3).Info(
+			"re-encrypt succeeded.",
+			"updated",
+			n,
+			"duration",
+			time.Since(mark))
+	}
+
+	return
+}
+
+//
+// Compress compressed fields.
+// One-time migration of stored values not compressed. Intended
+// to be called after the `compress` option has been added
+// to existing fields. Events are not reported.
+// Returns: the number of models updated.
+func (r *Client) Compress() (n int64, err error) {
+	mark := time.Now()
+	keys := r.settings.Keys
+	n, err = r.rewrite(
+		func(f *Field) bool {
+			return f.Compressed()
+		},
+		func(f *Field, stored string, blob bool, aad string) (value interface{}, changed bool, err error) {
+			value = stored
+			if blob {
+				value = []byte(stored)
+			}
+			plain, compressed, err := f.decode(keys, aad, stored, blob)
+			if err != nil || compressed {
+				return
+			}
+			value, err = f.encode(keys, aad, plain)
+			if err != nil {
+				return
+			}
+			changed = true
+			return
+		})
+	if err == nil {
+		r.log.V(3).Info(
+			"compress succeeded.",
+			"updated",
+			n,
+			"duration",
+			time.Since(mark))
+	}
+
+	return
+}
+
+//
+// Determine whether any field is selected.
+func (r *Client) selected(selector func(*Field) bool) bool {
+	for _, md := range r.dm.Definitions() {
+		for _, f := range md.Fields {
+			if selector(f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//
+// Rewrite the stored value of selected fields in all tables.
+// Returns: the number of models updated.
+func (r *Client) rewrite(selector func(*Field) bool, fn RewriteFn) (n int64, err error) {
+	err = r.With(func(tx *Tx) (err error) {
+		for _, md := range r.dm.Definitions() {
+			fields := []*Field{}
+			for _, f := range md.Fields {
+				if selector(f) {
+					fields = append(fields, f)
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			var updated int64
+			updated, err = tx.table().rewrite(md, fields, fn)
+			if err != nil {
+				return
+			}
+			n += updated
+		}
+		return
+	})
+
+	return
+}
+
 //
 // Watch model events.
+// The (journal) commit mutex is held while the watch is
+// registered and the snapshot (read transaction) is started.
+// Transactions are committed and reported while holding the
+// mutex so the snapshot is consistent with the last event ID
+// reported by the journal (boundary). Only events after the
+// boundary are delivered. The writer is not reserved so the
+// watch is not blocked by (long) transactions.
 func (r *Client) Watch(model Model, handler EventHandler) (w *Watch, err error) {
 	mark := time.Now()
+	options := handler.Options()
+	kinds := r.watched(model, options)
+	if options.AllKinds {
+		err = buildPredicate(options.Predicate, kinds)
+		if err != nil {
+			return
+		}
+	}
+	locked := true
+	r.journal.commit.Lock()
+	release := func() {
+		if locked {
+			r.journal.commit.Unlock()
+			locked = false
+		}
+	}
+	defer release()
 	w, err = r.journal.Watch(model, handler)
 	if err != nil {
 		return
@@ -330,10 +643,21 @@ func (r *Client) Watch(model Model, handler EventHandler) (w *Watch, err error)
 			w = nil
 		}
 	}()
-	options := handler.Options()
 	var snapshot fb.Iterator
-	if options.Snapshot {
-		snapshot, err = r.Find(model, ListOptions{Detail: MaxDetail})
+	if options.Snapshot && options.ResumeFrom == 0 {
+		reader := r.pool.Reader()
+		defer reader.Return()
+		var tx *sql.Tx
+		tx, err = reader.Begin()
+		if err != nil {
+			return
+		}
+		err = r.pin(tx)
+		if err != nil {
+			return
+		}
+		release()
+		snapshot, err = r.snapshot(tx, kinds, options.Predicate)
 		if err != nil {
 			return
 		}
@@ -341,12 +665,13 @@ func (r *Client) Watch(model Model, handler EventHandler) (w *Watch, err error)
 		snapshot = &fb.EmptyIterator{}
 	}
 
+	release()
 	w.Start(snapshot)
 
 	r.log.V(4).Info(
 		"watch started.",
-		"model",
-		Describe(model),
+		"watch",
+		w.String(),
 		"options",
 		options,
 		"duration",
@@ -361,18 +686,300 @@ func (r *Client) EndWatch(watch *Watch) {
 	r.journal.End(watch)
 	r.log.V(4).Info(
 		"watch ended.",
-		"model",
-		Describe(watch.Model))
+		"watch",
+		watch.String())
+}
+
+//
+// Status of watches.
+func (r *Client) WatchStatus() []WatchStatus {
+	return r.journal.Status()
+}
+
+//
+// Watched kinds (models).
+// All kinds excludes internal kinds.
+func (r *Client) watched(model Model, options WatchOptions) (kinds []Model) {
+	if options.AllKinds {
+		for _, md := range r.dm.Definitions() {
+			if !r.dm.Internal(md) {
+				kinds = append(kinds, md.NewModel().(Model))
+			}
+		}
+		return
+	}
+	if model != nil {
+		kinds = append(kinds, model)
+	}
+
+	kinds = append(kinds, options.Kinds...)
+
+	return
+}
+
+//
+// Pin the (read) transaction snapshot.
+// The snapshot is established by the first read.
+func (r *Client) pin(tx *sql.Tx) (err error) {
+	n := 0
+	err = tx.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Snapshot of the watched kinds.
+func (r *Client) snapshot(tx *sql.Tx, kinds []Model, predicate Predicate) (itr fb.Iterator, err error) {
+	options := ListOptions{
+		Detail:    MaxDetail,
+		Predicate: predicate,
+	}
+	if len(kinds) == 1 {
+		itr, err = r.table(tx).Find(kinds[0], options)
+		return
+	}
+	list := fb.NewList()
+	defer list.Close()
+	for _, m := range kinds {
+		var kItr fb.Iterator
+		kItr, err = r.table(tx).Find(m, options)
+		if err != nil {
+			return
+		}
+		list.Append(kItr)
+		kItr.Close()
+	}
+
+	itr = list.Iter()
+
+	return
+}
+
+//
+// Export (CDC) committed events.
+// See: Exporter.
+func (r *Client) Export(exporter *Exporter) (err error) {
+	if !r.settings.Export {
+		err = liberr.Wrap(ExportErr)
+		return
+	}
+	exporter.client = r
+	err = exporter.load()
+	if err != nil {
+		return
+	}
+	trimmed, err := r.exportMarkOf("")
+	if err != nil {
+		return
+	}
+	if exporter.Last() < uint64(trimmed) {
+		err = liberr.Wrap(
+			ExportGapErr,
+			"checkpoint",
+			exporter.Checkpoint,
+			"last",
+			exporter.Last(),
+			"trimmed",
+			trimmed)
+		return
+	}
+	err = r.exportMark(exporter.Checkpoint, exporter.Last())
+	if err != nil {
+		return
+	}
+	err = r.journal.Export(exporter)
+	if err == nil {
+		r.log.V(4).Info(
+			"export started.",
+			"checkpoint",
+			exporter.Checkpoint)
+	}
+
+	return
+}
+
+//
+// Register a trigger.
+// The `actions` is a mask of: Created|Updated|Deleted.
+// See: Triggers.
+func (r *Client) Trigger(model Model, actions uint8, fn TriggerFn) {
+	r.triggers.Add(model, actions, fn)
+	r.log.V(3).Info(
+		"trigger registered.",
+		"kind",
+		ref.ToKind(model),
+		"actions",
+		actions)
+}
+
+//
+// End an export.
+// The export mark is retained and the outbox is not
+// trimmed past the checkpoint.
+func (r *Client) EndExport(exporter *Exporter) {
+	r.journal.EndExport(exporter)
+	r.log.V(4).Info(
+		"export ended.",
+		"checkpoint",
+		exporter.Checkpoint)
+}
+
+//
+// Read (CDC) outbox records.
+// Returns: records after the event ID ordered by ID.
+func (r *Client) outbox(after uint64, limit int) (records []Record, err error) {
+	session := r.pool.Reader()
+	defer session.Return()
+	list := []Outbox{}
+	err = r.table(session.db).List(
+		&list,
+		ListOptions{
+			Detail:    MaxDetail,
+			Predicate: Gt("ID", int64(after)),
+			Sort:      []int{1},
+			Page: &Page{
+				Limit: limit,
+			},
+		})
+	if err != nil {
+		return
+	}
+	for i := range list {
+		var record Record
+		record, err = list[i].record(r.dm, r.settings.Keys)
+		if err != nil {
+			return
+		}
+		records = append(records, record)
+	}
+
+	return
+}
+
+//
+// Delete (CDC) outbox entries exported by all
+// exporters (ever registered) and update the trimmed mark.
+// See: ExportMark.
+func (r *Client) trimOutbox() (err error) {
+	session := r.pool.Writer()
+	defer session.Return()
+	tx, err := session.db.Begin()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	var last sql.NullInt64
+	row := tx.QueryRow("SELECT MIN(Last) FROM ExportMark WHERE Checkpoint != '';")
+	err = row.Scan(&last)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	if !last.Valid {
+		err = tx.Rollback()
+		if err != nil {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	_, err = tx.Exec("DELETE FROM Outbox WHERE ID <= ?;", last.Int64)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	_, err = tx.Exec(
+		"INSERT INTO ExportMark (Checkpoint, Last) VALUES ('', ?) "+
+			"ON CONFLICT(Checkpoint) DO UPDATE SET Last = MAX(Last, excluded.Last);",
+		last.Int64)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	r.log.V(5).Info(
+		"outbox trimmed.",
+		"last",
+		last.Int64)
+
+	return
+}
+
+//
+// Record the (durable) export mark.
+func (r *Client) exportMark(checkpoint string, last uint64) (err error) {
+	session := r.pool.Writer()
+	defer session.Return()
+	_, err = session.db.Exec(
+		"INSERT INTO ExportMark (Checkpoint, Last) VALUES (?, ?) "+
+			"ON CONFLICT(Checkpoint) DO UPDATE SET Last = excluded.Last;",
+		checkpoint,
+		int64(last))
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Get the (durable) export mark.
+// Returns: 0 when not found.
+func (r *Client) exportMarkOf(checkpoint string) (last int64, err error) {
+	session := r.pool.Reader()
+	defer session.Return()
+	row := session.db.QueryRow(
+		"SELECT Last FROM ExportMark WHERE Checkpoint = ?;",
+		checkpoint)
+	err = row.Scan(&last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		} else {
+			err = liberr.Wrap(err)
+		}
+	}
+
+	return
 }
 
 //
 // Build the data model.
 func (r *Client) build() (err error) {
-	r.models = append(r.models, &Label{})
 	r.dm, err = NewModel(r.models)
 	if err != nil {
 		return err
 	}
+	err = r.dm.AddInternal(&Label{}, &Outbox{}, &ExportMark{})
+	if err != nil {
+		return err
+	}
+	if r.settings.Keys == nil {
+		for _, md := range r.dm.Definitions() {
+			for _, f := range md.Fields {
+				if f.Encrypted() {
+					return liberr.Wrap(
+						KeyProviderErr,
+						"kind",
+						md.Kind,
+						"field",
+						f.Name)
+				}
+			}
+		}
+	}
 	ddls, err := r.dm.DDL()
 	if err != nil {
 		return err
@@ -394,6 +1001,19 @@ func (r *Client) build() (err error) {
 				ddl)
 		}
 	}
+	if r.settings.Export {
+		var last sql.NullInt64
+		row := session.db.QueryRow(
+			"SELECT MAX(ID) FROM (" +
+				"SELECT MAX(ID) AS ID FROM Outbox " +
+				"UNION ALL " +
+				"SELECT MAX(Last) FROM ExportMark);")
+		err = row.Scan(&last)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		serial.seed(1, uint64(last.Int64))
+	}
 
 	return nil
 }
@@ -407,6 +1027,10 @@ type Tx struct {
 	journal *Journal
 	// Real transaction.
 	real *sql.Tx
+	// Key provider.
+	keys KeyProvider
+	// Record events in the (CDC) outbox.
+	export bool
 	// Staged events.
 	staged *fb.List
 	// Manage labels associated with models.
@@ -419,10 +1043,41 @@ type Tx struct {
 	started time.Time
 	// Labels associated with the transaction.
 	labels []string
+	// Savepoints (stack).
+	savepoints []savepoint
+	// Triggers.
+	triggers *Triggers
+	// Trigger depth.
+	depth int
+	// Failed (by trigger).
+	// The transaction must be rolled back.
+	failed error
 	// Ended.
 	ended bool
 }
 
+//
+// Transaction savepoint.
+type savepoint struct {
+	// Name.
+	name string
+	// Number of staged (event) entries.
+	staged int
+}
+
+//
+// Regex used to validate savepoint names.
+var SavepointRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//
+// Table using the (real) transaction.
+func (r *Tx) table() Table {
+	return Table{
+		DB:   r.real,
+		Keys: r.keys,
+	}
+}
+
 //
 // Execute SQL.
 func (r *Tx) Execute(sql string) (result sql.Result, err error) {
@@ -444,7 +1099,7 @@ func (r *Tx) Execute(sql string) (result sql.Result, err error) {
 // Get the model.
 func (r *Tx) Get(model Model) (err error) {
 	mark := time.Now()
-	err = Table{r.real}.Get(model)
+	err = r.table().Get(model)
 	if err == nil {
 		r.log.V(4).Info(
 			"get succeeded.",
@@ -462,7 +1117,7 @@ func (r *Tx) Get(model Model) (err error) {
 // The `list` must be: *[]Model.
 func (r *Tx) List(list interface{}, options ListOptions) (err error) {
 	mark := time.Now()
-	err = Table{r.real}.List(list, options)
+	err = r.table().List(list, options)
 	if err == nil {
 		r.log.V(4).Info(
 			"list succeeded.",
@@ -479,7 +1134,7 @@ func (r *Tx) List(list interface{}, options ListOptions) (err error) {
 // List models.
 func (r *Tx) Find(model interface{}, options ListOptions) (itr fb.Iterator, err error) {
 	mark := time.Now()
-	itr, err = Table{r.real}.Find(model, options)
+	itr, err = r.table().Find(model, options)
 	if err == nil {
 		r.log.V(4).Info(
 			"iter succeeded",
@@ -492,11 +1147,29 @@ func (r *Tx) Find(model interface{}, options ListOptions) (itr fb.Iterator, err
 	return
 }
 
+//
+// Stream models.
+// The cursor must be closed before the transaction is ended.
+func (r *Tx) Stream(model interface{}, options ListOptions) (cursor *Cursor, err error) {
+	mark := time.Now()
+	cursor, err = r.table().Stream(model, options)
+	if err == nil {
+		r.log.V(4).Info(
+			"stream succeeded.",
+			"options",
+			options,
+			"duration",
+			time.Since(mark))
+	}
+
+	return
+}
+
 //
 // Count models.
 func (r *Tx) Count(model Model, predicate Predicate) (n int64, err error) {
 	mark := time.Now()
-	n, err = Table{r.real}.Count(model, predicate)
+	n, err = r.table().Count(model, predicate)
 	if err == nil {
 		r.log.V(4).Info(
 			"count succeeded.",
@@ -512,21 +1185,30 @@ func (r *Tx) Count(model Model, predicate Predicate) (n int64, err error) {
 // Insert the model.
 func (r *Tx) Insert(model Model) (err error) {
 	mark := time.Now()
-	err = Table{r.real}.Insert(model)
+	err = r.table().Insert(model)
 	if err != nil {
 		return
 	}
 	event := Event{
-		ID:     serial.next(1),
-		Labels: r.labels,
-		Action: Created,
-		Model:  model,
+		ID:          serial.next(1),
+		Labels:      r.labels,
+		Action:      Created,
+		Model:       model,
+		ModelLabels: labels(model),
 	}
 	event.append(r.staged)
+	err = r.outbox(event)
+	if err != nil {
+		return
+	}
 	err = r.labeler.Insert(model)
 	if err != nil {
 		return
 	}
+	err = r.fire(event)
+	if err != nil {
+		return
+	}
 
 	r.log.V(3).Info(
 		"insert succeeded.",
@@ -544,26 +1226,40 @@ func (r *Tx) Update(model Model, predicate ...Predicate) (err error) {
 	mark := time.Now()
 	current := model
 	current = Clone(model)
-	err = Table{r.real}.Get(current)
+	err = r.table().Get(current)
 	if err != nil {
 		return
 	}
-	err = Table{r.real}.Update(model, predicate...)
+	err = r.table().Update(model, predicate...)
+	if err != nil {
+		return
+	}
+	prior, err := r.labeler.Labels(model)
 	if err != nil {
 		return
 	}
 	event := Event{
-		ID:      serial.next(1),
-		Labels:  r.labels,
-		Action:  Updated,
-		Model:   current,
-		Updated: model,
+		ID:          serial.next(1),
+		Labels:      r.labels,
+		Action:      Updated,
+		Model:       current,
+		Updated:     model,
+		ModelLabels: labels(model),
+		PriorLabels: prior,
 	}
 	event.append(r.staged)
+	err = r.outbox(event)
+	if err != nil {
+		return
+	}
 	err = r.labeler.Replace(model)
 	if err != nil {
 		return
 	}
+	err = r.fire(event)
+	if err != nil {
+		return
+	}
 
 	r.log.V(3).Info(
 		"update succeeded.",
@@ -578,9 +1274,9 @@ func (r *Tx) Update(model Model, predicate ...Predicate) (err error) {
 //
 // Delete (cascading) of the model.
 func (r *Tx) Delete(model Model) (err error) {
-	err = Table{r.real}.Get(model)
+	err = r.table().Get(model)
 	if err != nil {
-		if errors.As(err, &NotFound) {
+		if errors.Is(err, NotFound) {
 			return
 		}
 		return
@@ -612,16 +1308,24 @@ func (r *Tx) Delete(model Model) (err error) {
 // Commit a transaction.
 // Staged changes are committed in the DB.
 // The transaction is ended and the session returned.
+// A transaction failed by a trigger is rolled back.
 func (r *Tx) Commit() (err error) {
 	if r.ended {
 		return
 	}
+	if r.failed != nil {
+		err = r.failed
+		_ = r.End()
+		return
+	}
 	r.ended = true
+	r.journal.commit.Lock()
 	defer func() {
-		r.session.Return()
 		if err == nil {
 			r.report()
 		}
+		r.journal.commit.Unlock()
+		r.session.Return()
 	}()
 	mark := time.Now()
 	err = r.real.Commit()
@@ -668,30 +1372,154 @@ func (r *Tx) End() (err error) {
 	return
 }
 
+//
+// Create a savepoint.
+// Changes made after the savepoint may be rolled back
+// using RollbackTo() without ending the transaction.
+func (r *Tx) Savepoint(name string) (err error) {
+	if !SavepointRegex.MatchString(name) {
+		err = liberr.Wrap(SavepointNameErr, "name", name)
+		return
+	}
+	_, err = r.real.Exec("SAVEPOINT " + name)
+	if err != nil {
+		err = liberr.Wrap(err, "name", name)
+		return
+	}
+	r.savepoints = append(
+		r.savepoints,
+		savepoint{
+			name:   name,
+			staged: r.staged.Len(),
+		})
+
+	r.log.V(4).Info(
+		"tx: savepoint created.",
+		"name",
+		name)
+
+	return
+}
+
+//
+// Rollback to a savepoint.
+// Changes made after the savepoint are discarded and
+// the staged events are trimmed. Savepoints created after
+// the named savepoint are released. The named savepoint
+// remains active.
+func (r *Tx) RollbackTo(name string) (err error) {
+	index, err := r.savepoint(name)
+	if err != nil {
+		return
+	}
+	_, err = r.real.Exec("ROLLBACK TO SAVEPOINT " + name)
+	if err != nil {
+		err = liberr.Wrap(err, "name", name)
+		return
+	}
+	mark := r.savepoints[index]
+	r.savepoints = r.savepoints[:index+1]
+	r.trim(mark.staged)
+
+	r.log.V(4).Info(
+		"tx: rolled back to savepoint.",
+		"name",
+		name)
+
+	return
+}
+
+//
+// Release a savepoint.
+// Changes made after the savepoint are retained. The named
+// savepoint and savepoints created after it are released.
+func (r *Tx) Release(name string) (err error) {
+	index, err := r.savepoint(name)
+	if err != nil {
+		return
+	}
+	_, err = r.real.Exec("RELEASE SAVEPOINT " + name)
+	if err != nil {
+		err = liberr.Wrap(err, "name", name)
+		return
+	}
+	r.savepoints = r.savepoints[:index]
+
+	r.log.V(4).Info(
+		"tx: savepoint released.",
+		"name",
+		name)
+
+	return
+}
+
+//
+// Find the (most recent) savepoint by name.
+// Returns: the index in the stack.
+func (r *Tx) savepoint(name string) (index int, err error) {
+	for index = len(r.savepoints) - 1; index >= 0; index-- {
+		if r.savepoints[index].name == name {
+			return
+		}
+	}
+	err = liberr.Wrap(SavepointNotFoundErr, "name", name)
+	return
+}
+
+//
+// Trim staged events.
+// Only the first (n) entries are retained.
+func (r *Tx) trim(n int) {
+	if r.staged.Len() == n {
+		return
+	}
+	staged := fb.NewList()
+	itr := r.staged.Iter()
+	defer itr.Close()
+	for i := 0; i < n; i++ {
+		object, hasNext := itr.Next()
+		if !hasNext {
+			break
+		}
+		staged.Append(object)
+	}
+	r.staged.Close()
+	r.staged = staged
+}
+
 //
 // Raw Delete.
 // Non-cascading delete of the model.
 // The model must be complete (fetched from the DB).
 func (r *Tx) delete(model Model) (err error) {
 	mark := time.Now()
-	err = Table{r.real}.Delete(model)
+	err = r.table().Delete(model)
 	if err != nil {
-		if errors.As(err, &NotFound) {
+		if errors.Is(err, NotFound) {
 			err = nil
 		}
 		return
 	}
 	event := Event{
-		ID:     serial.next(1),
-		Labels: r.labels,
-		Action: Deleted,
-		Model:  model,
+		ID:          serial.next(1),
+		Labels:      r.labels,
+		Action:      Deleted,
+		Model:       model,
+		ModelLabels: labels(model),
 	}
 	event.append(r.staged)
+	err = r.outbox(event)
+	if err != nil {
+		return
+	}
 	err = r.labeler.Delete(model)
 	if err != nil {
 		return
 	}
+	err = r.fire(event)
+	if err != nil {
+		return
+	}
 
 	r.log.V(3).Info(
 		"delete succeeded.",
@@ -703,6 +1531,52 @@ func (r *Tx) delete(model Model) (err error) {
 	return
 }
 
+//
+// Record the event in the (CDC) outbox.
+// Written in the transaction so that the event is
+// persisted if and only if the change is committed.
+func (r *Tx) outbox(event Event) (err error) {
+	if !r.export {
+		return
+	}
+	entry := &Outbox{}
+	err = entry.With(event, r.keys)
+	if err != nil {
+		return
+	}
+	err = r.table().Insert(entry)
+
+	return
+}
+
+//
+// Fire triggers.
+// On error, the transaction is marked as failed and
+// will be rolled back.
+func (r *Tx) fire(event Event) (err error) {
+	if r.triggers == nil {
+		return
+	}
+	if r.depth >= TriggerDepth {
+		err = liberr.Wrap(
+			TriggerDepthErr,
+			"event",
+			event.String())
+		r.failed = err
+		return
+	}
+	r.depth++
+	defer func() {
+		r.depth--
+	}()
+	err = r.triggers.fire(r, event)
+	if err != nil {
+		r.failed = err
+	}
+
+	return
+}
+
 //
 // Report staged events to the journal.
 func (r *Tx) report() {
@@ -725,7 +1599,7 @@ type Labeler struct {
 //
 // Insert labels for the model into the DB.
 func (r *Labeler) Insert(model Model) (err error) {
-	table := Table{r.tx}
+	table := Table{DB: r.tx}
 	kind := table.Name(model)
 	if labeled, cast := model.(Labeled); cast {
 		for l, v := range labeled.Labels() {
@@ -762,7 +1636,7 @@ func (r *Labeler) Delete(model Model) (err error) {
 		return
 	}
 	list := []Label{}
-	table := Table{r.tx}
+	table := Table{DB: r.tx}
 	err = table.List(
 		&list,
 		ListOptions{
@@ -793,6 +1667,33 @@ func (r *Labeler) Delete(model Model) (err error) {
 	return
 }
 
+//
+// Labels for the model in the DB.
+func (r *Labeler) Labels(model Model) (labels Labels, err error) {
+	if _, cast := model.(Labeled); !cast {
+		return
+	}
+	list := []Label{}
+	table := Table{DB: r.tx}
+	err = table.List(
+		&list,
+		ListOptions{
+			Detail: MaxDetail,
+			Predicate: And(
+				Eq("Kind", table.Name(model)),
+				Eq("Parent", model.Pk())),
+		})
+	if err != nil {
+		return
+	}
+	labels = Labels{}
+	for _, label := range list {
+		labels[label.Name] = label.Value
+	}
+
+	return
+}
+
 //
 // Replace labels.
 func (r *Labeler) Replace(model Model) (err error) {
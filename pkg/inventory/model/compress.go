@@ -0,0 +1,51 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"io/ioutil"
+)
+
+//
+// Compress the value.
+func compress(plain string) (compressed string, err error) {
+	bfr := &bytes.Buffer{}
+	writer := gzip.NewWriter(bfr)
+	_, err = writer.Write([]byte(plain))
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = writer.Close()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	compressed = bfr.String()
+
+	return
+}
+
+//
+// Decompress the value.
+func decompress(compressed string) (plain string, err error) {
+	reader, err := gzip.NewReader(bytes.NewBufferString(compressed))
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	plain = string(b)
+
+	return
+}
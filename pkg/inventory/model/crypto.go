@@ -0,0 +1,357 @@
+package model
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Encrypted value prefix.
+// Format: enc:<key-id>:<base64(nonce+ciphertext)>
+// Encrypted values are stored as BLOB. Values stored as
+// TEXT are (legacy) plain values.
+const EncPrefix = "enc:"
+
+//
+// Errors.
+var (
+	// Key provider not set.
+	KeyProviderErr = errors.New("key provider (Settings.Keys) not set")
+	// Key not found.
+	KeyNotFoundErr = errors.New("encryption key not found")
+	// Encrypted value not valid.
+	CipherTextErr = errors.New("encrypted value not valid")
+)
+
+//
+// Encryption key provider.
+type KeyProvider interface {
+	// The current key (used to encrypt) and its ID.
+	Current() (id string, key []byte, err error)
+	// Get a key by ID (used to decrypt).
+	Key(id string) (key []byte, err error)
+}
+
+//
+// Local key-file provider.
+// Each line in the file is: <id> <base64(key)>. The last
+// key is the current key. The file is created with a new
+// key as needed. The file is reloaded when changed (keys
+// rotated) by another process.
+type KeyFile struct {
+	// File path.
+	Path string
+	// Mutex.
+	mutex sync.Mutex
+	// Keys by ID.
+	keys map[string][]byte
+	// The current key ID.
+	current string
+	// File size when loaded.
+	size int64
+	// File modification time when loaded.
+	modTime time.Time
+}
+
+//
+// The current key.
+func (r *KeyFile) Current() (id string, key []byte, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	if r.current == "" {
+		err = r.rotate()
+		if err != nil {
+			return
+		}
+	}
+	id = r.current
+	key = r.keys[id]
+	return
+}
+
+//
+// Get key by ID.
+func (r *KeyFile) Key(id string) (key []byte, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	key, found := r.keys[id]
+	if !found {
+		err = liberr.Wrap(
+			KeyNotFoundErr,
+			"id",
+			id)
+	}
+
+	return
+}
+
+//
+// Rotate keys.
+// A new (current) key is generated and added to the file.
+// Existing keys are retained to decrypt values until
+// re-encrypted. See: Client.Reencrypt().
+func (r *KeyFile) Rotate() (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	err = r.rotate()
+	return
+}
+
+//
+// Generate and add the new (current) key.
+func (r *KeyFile) rotate() (err error) {
+	key := make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, key)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	id := fmt.Sprintf("k%d", len(r.keys)+1)
+	fp, err := os.OpenFile(
+		r.Path,
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0600)
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	_, err = fmt.Fprintf(
+		fp,
+		"%s %s\n",
+		id,
+		base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	err = fp.Sync()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	r.keys[id] = key
+	r.current = id
+	st, err := fp.Stat()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	r.size = st.Size()
+	r.modTime = st.ModTime()
+
+	log.V(3).Info(
+		"key-file: key added.",
+		"path",
+		r.Path,
+		"id",
+		id)
+
+	return
+}
+
+//
+// Load the key file.
+// The file is (re)loaded when changed since last loaded.
+func (r *KeyFile) load() (err error) {
+	keys := map[string][]byte{}
+	current := ""
+	fp, err := os.Open(r.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			if r.keys == nil {
+				r.keys = keys
+			}
+		} else {
+			err = liberr.Wrap(err, "path", r.Path)
+		}
+		return
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	st, err := fp.Stat()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+	if r.keys != nil &&
+		st.Size() == r.size &&
+		st.ModTime().Equal(r.modTime) {
+		return
+	}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		part := strings.Fields(scanner.Text())
+		if len(part) != 2 {
+			continue
+		}
+		key, dErr := base64.StdEncoding.DecodeString(part[1])
+		if dErr != nil {
+			err = liberr.Wrap(dErr, "path", r.Path)
+			return
+		}
+		keys[part[0]] = key
+		current = part[0]
+	}
+	err = scanner.Err()
+	if err != nil {
+		err = liberr.Wrap(err, "path", r.Path)
+		return
+	}
+
+	r.keys = keys
+	r.current = current
+	r.size = st.Size()
+	r.modTime = st.ModTime()
+
+	log.V(3).Info(
+		"key-file: loaded.",
+		"path",
+		r.Path,
+		"current",
+		current)
+
+	return
+}
+
+//
+// Additional authenticated data (AAD).
+// Binds the encrypted value to the model (kind), the row
+// (pk) and the field so values cannot be swapped.
+func aad(kind, pk, field string) string {
+	return strings.Join([]string{kind, pk, field}, "\x00")
+}
+
+//
+// AAD for compressed values.
+func zipped(aad string) string {
+	return aad + "\x00gzip"
+}
+
+//
+// Encrypt the value using the current key.
+func encrypt(keys KeyProvider, plain, aad string) (encrypted string, err error) {
+	if keys == nil {
+		err = liberr.Wrap(KeyProviderErr)
+		return
+	}
+	id, key, err := keys.Current()
+	if err != nil {
+		return
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), []byte(aad))
+	encrypted = EncPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed)
+	return
+}
+
+//
+// Decrypt the value.
+// The AAD must match the AAD used to encrypt.
+func decrypt(keys KeyProvider, encrypted, aad string) (plain string, err error) {
+	if !strings.HasPrefix(encrypted, EncPrefix) {
+		err = liberr.Wrap(CipherTextErr)
+		return
+	}
+	if keys == nil {
+		err = liberr.Wrap(KeyProviderErr)
+		return
+	}
+	part := strings.SplitN(encrypted[len(EncPrefix):], ":", 2)
+	if len(part) != 2 {
+		err = liberr.Wrap(CipherTextErr)
+		return
+	}
+	key, err := keys.Key(part[0])
+	if err != nil {
+		return
+	}
+	sealed, err := base64.StdEncoding.DecodeString(part[1])
+	if err != nil {
+		err = liberr.Wrap(CipherTextErr, "reason", err.Error())
+		return
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	if len(sealed) < gcm.NonceSize() {
+		err = liberr.Wrap(CipherTextErr)
+		return
+	}
+	nonce := sealed[:gcm.NonceSize()]
+	b, err := gcm.Open(nil, nonce, sealed[gcm.NonceSize():], []byte(aad))
+	if err != nil {
+		err = liberr.Wrap(CipherTextErr, "reason", err.Error())
+		return
+	}
+
+	plain = string(b)
+
+	return
+}
+
+//
+// Get the ID of the key used to encrypt the value.
+// Returns: "" when not encrypted.
+func encryptedWith(encrypted string) (id string) {
+	if !strings.HasPrefix(encrypted, EncPrefix) {
+		return
+	}
+	part := strings.SplitN(encrypted[len(EncPrefix):], ":", 2)
+	id = part[0]
+	return
+}
+
+//
+// Build AES-GCM cipher.
+func newGCM(key []byte) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"database/sql"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"reflect"
+)
+
+//
+// Streaming cursor.
+// Models are scanned lazily from the live (sql) rows as
+// the cursor is advanced. Intended for one-pass consumers.
+// Unlike the fb.Iterator returned by Find(), the result is
+// not materialized so random access is not supported.
+// The cursor MUST be closed. It is closed automatically
+// when exhausted.
+type Cursor struct {
+	// Reserved session.
+	// Returned when the cursor is closed.
+	session *Session
+	// Live rows.
+	rows *sql.Rows
+	// Key provider.
+	keys KeyProvider
+	// Model type.
+	mt reflect.Type
+	// List options.
+	options ListOptions
+	// Number of models scanned.
+	count int
+	// Last error.
+	err error
+	// Closed.
+	closed bool
+}
+
+//
+// Next model.
+// Returns: (nil, false) when exhausted or an error
+// has occurred. See: Err().
+func (r *Cursor) Next() (m Model, hasNext bool) {
+	if r.closed {
+		return
+	}
+	if !r.rows.Next() {
+		r.err = r.rows.Err()
+		if r.err != nil {
+			r.err = liberr.Wrap(r.err)
+		}
+		r.Close()
+		return
+	}
+	mPtr := reflect.New(r.mt)
+	md, err := Inspect(mPtr.Interface())
+	if err != nil {
+		r.err = err
+		r.Close()
+		return
+	}
+	r.options.fields = md.Fields
+	err = Table{Keys: r.keys}.scan(r.rows, md.Kind, r.options.Fields())
+	if err != nil {
+		r.err = err
+		r.Close()
+		return
+	}
+	r.count++
+	m = mPtr.Interface().(Model)
+	hasNext = true
+	return
+}
+
+//
+// Next model (with).
+// The model is populated using the next model.
+// Returns: false when exhausted or an error has
+// occurred. See: Err().
+func (r *Cursor) NextWith(object interface{}) (hasNext bool) {
+	m, hasNext := r.Next()
+	if hasNext {
+		rv := reflect.ValueOf(object)
+		rv.Elem().Set(reflect.ValueOf(m).Elem())
+	}
+
+	return
+}
+
+//
+// Number of models scanned.
+func (r *Cursor) Count() int {
+	return r.count
+}
+
+//
+// The error that terminated the cursor.
+func (r *Cursor) Err() error {
+	return r.err
+}
+
+//
+// Close the cursor.
+// The rows are closed and the session returned.
+func (r *Cursor) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	_ = r.rows.Close()
+	if r.session != nil {
+		r.session.Return()
+		r.session = nil
+	}
+
+	log.V(5).Info(
+		"cursor: closed.",
+		"scanned",
+		r.count)
+}
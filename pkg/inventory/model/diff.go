@@ -0,0 +1,304 @@
+package model
+
+import (
+	"encoding/json"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// JSON patch operations.
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+//
+// Field change.
+type Change struct {
+	// Field name.
+	Field string `json:"field"`
+	// Original value.
+	// Omitted for encrypted fields.
+	Old interface{} `json:"old,omitempty"`
+	// Updated value.
+	// Omitted for encrypted fields.
+	New interface{} `json:"new,omitempty"`
+	// JSON patch (RFC 6902) for encoded fields.
+	// Paths are relative to the field.
+	Patch []PatchOp `json:"patch,omitempty"`
+}
+
+//
+// JSON patch (RFC 6902) operation.
+type PatchOp struct {
+	// Operation (add|remove|replace).
+	Op string `json:"op"`
+	// JSON pointer (RFC 6901).
+	Path string `json:"path"`
+	// Value.
+	Value interface{} `json:"value"`
+}
+
+//
+// JSON representation.
+// The value is omitted for `remove` operations.
+func (r PatchOp) MarshalJSON() ([]byte, error) {
+	op := map[string]interface{}{
+		"op":   r.Op,
+		"path": r.Path,
+	}
+	if r.Op != PatchRemove {
+		op["value"] = r.Value
+	}
+
+	return json.Marshal(op)
+}
+
+//
+// Field changes between the original and updated model.
+// Virtual fields are ignored.
+func Diff(model, updated Model) (changes []Change, err error) {
+	mdA, err := Inspect(model)
+	if err != nil {
+		return
+	}
+	mdB, err := Inspect(updated)
+	if err != nil {
+		return
+	}
+	for _, fA := range mdA.Fields {
+		if fA.Virtual() {
+			continue
+		}
+		fB := mdB.Field(fA.Name)
+		if fB == nil {
+			continue
+		}
+		a := fA.Value.Interface()
+		b := fB.Value.Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		change := Change{Field: fA.Name}
+		switch {
+		case fA.Encrypted():
+		case fA.Encoded():
+			change.Patch, err = patch(a, b)
+			if err != nil {
+				return
+			}
+			if len(change.Patch) == 0 {
+				continue
+			}
+			change.Old = a
+			change.New = b
+		default:
+			change.Old = a
+			change.New = b
+		}
+		changes = append(changes, change)
+	}
+
+	return
+}
+
+//
+// Changes between the JSON representation of the original
+// and updated objects (for example: REST resources). Each
+// changed (top-level) property is a change. Objects and
+// arrays include the JSON patch.
+func DiffJSON(object, updated interface{}) (changes []Change, err error) {
+	gA, err := generic(object)
+	if err != nil {
+		return
+	}
+	gB, err := generic(updated)
+	if err != nil {
+		return
+	}
+	mA, castA := gA.(map[string]interface{})
+	mB, castB := gB.(map[string]interface{})
+	if !castA || !castB {
+		err = liberr.New("object must be represented as a JSON object.")
+		return
+	}
+	keys := []string{}
+	for k := range mA {
+		keys = append(keys, k)
+	}
+	for k := range mB {
+		if _, found := mA[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		a, b := mA[k], mB[k]
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		change := Change{
+			Field: k,
+			Old:   a,
+			New:   b,
+		}
+		switch a.(type) {
+		case map[string]interface{}, []interface{}:
+			if reflect.TypeOf(a) == reflect.TypeOf(b) {
+				change.Patch = diffValue("", a, b)
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return
+}
+
+//
+// Build the JSON patch.
+// The objects are compared using their JSON representation.
+func patch(a, b interface{}) (ops []PatchOp, err error) {
+	gA, err := generic(a)
+	if err != nil {
+		return
+	}
+	gB, err := generic(b)
+	if err != nil {
+		return
+	}
+
+	ops = diffValue("", gA, gB)
+
+	return
+}
+
+//
+// Generic (unmarshalled) JSON representation.
+func generic(in interface{}) (out interface{}, err error) {
+	j, err := json.Marshal(in)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = json.Unmarshal(j, &out)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Diff (generic) JSON values.
+func diffValue(path string, a, b interface{}) (ops []PatchOp) {
+	switch a.(type) {
+	case map[string]interface{}:
+		if mB, cast := b.(map[string]interface{}); cast {
+			return diffMap(path, a.(map[string]interface{}), mB)
+		}
+	case []interface{}:
+		if sB, cast := b.([]interface{}); cast {
+			return diffSlice(path, a.([]interface{}), sB)
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		ops = append(
+			ops,
+			PatchOp{
+				Op:    PatchReplace,
+				Path:  path,
+				Value: b,
+			})
+	}
+
+	return
+}
+
+//
+// Diff (generic) JSON objects.
+func diffMap(path string, a, b map[string]interface{}) (ops []PatchOp) {
+	keys := []string{}
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kPath := path + "/" + escape(k)
+		vB, found := b[k]
+		if !found {
+			ops = append(
+				ops,
+				PatchOp{
+					Op:   PatchRemove,
+					Path: kPath,
+				})
+			continue
+		}
+		ops = append(ops, diffValue(kPath, a[k], vB)...)
+	}
+	keys = []string{}
+	for k := range b {
+		if _, found := a[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ops = append(
+			ops,
+			PatchOp{
+				Op:    PatchAdd,
+				Path:  path + "/" + escape(k),
+				Value: b[k],
+			})
+	}
+
+	return
+}
+
+//
+// Diff (generic) JSON arrays.
+// Elements are compared by index. Trailing elements
+// are added or removed (last first).
+func diffSlice(path string, a, b []interface{}) (ops []PatchOp) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		iPath := path + "/" + strconv.Itoa(i)
+		ops = append(ops, diffValue(iPath, a[i], b[i])...)
+	}
+	for i := n; i < len(b); i++ {
+		ops = append(
+			ops,
+			PatchOp{
+				Op:    PatchAdd,
+				Path:  path + "/" + strconv.Itoa(i),
+				Value: b[i],
+			})
+	}
+	for i := len(a) - 1; i >= n; i-- {
+		ops = append(
+			ops,
+			PatchOp{
+				Op:   PatchRemove,
+				Path: path + "/" + strconv.Itoa(i),
+			})
+	}
+
+	return
+}
+
+//
+// Escape a JSON pointer (RFC 6901) token.
+func escape(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	token = strings.Replace(token, "/", "~1", -1)
+	return token
+}
@@ -27,6 +27,20 @@
 //       The field detail level.  n = level number.
 //   `sql:incremented`
 //       The field is auto-incremented.
+//   `sql:"encrypt"`
+//       The field is encrypted at rest using the key
+//       provider (Settings.Keys). Must be (str, encoded) and
+//       may not be referenced in predicates. Values are bound
+//       to the kind, pk and field. Existing values are migrated
+//       using Client.Reencrypt().
+//   `sql:"compress"`
+//       The field is compressed (gzip) at rest. Must be
+//       (str, encoded) and may not be referenced in predicates.
+//       Existing values are migrated using Client.Compress().
+//   `sql:"ttl"`
+//       The (int) expires-at time in unix seconds. Expired
+//       models are deleted by the reaper. 0 = never expires.
+//       See: Settings.ReapInterval, Settings.ReapBatch.
 //
 // Each struct must implement the `Model` interface.
 // Basic CRUD operations may be performed on each model using
@@ -144,9 +158,23 @@ import (
 //
 // New database.
 func New(path string, models ...interface{}) DB {
+	return NewWith(path, Settings{}, models...)
+}
+
+//
+// New database with settings.
+// Example:
+//   DB := model.NewWith(
+//     path,
+//     model.Settings{
+//       Keys: &model.KeyFile{Path: "/var/lib/inventory/keys"},
+//     },
+//     &Person{})
+func NewWith(path string, settings Settings, models ...interface{}) DB {
 	client := &Client{
-		path:   path,
-		models: models,
+		path:     path,
+		models:   models,
+		settings: settings,
 	}
 	client.log = logging.WithName("model|db").WithValues(
 		"path",
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+//
+// Model not found.
+// Matches NotFound using errors.Is().
+type NotFoundError struct {
+	// Model kind.
+	Kind string
+	// Primary key.
+	Pk string
+}
+
+//
+// Error description.
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(
+		"%s (pk=%s) not found",
+		e.Kind,
+		e.Pk)
+}
+
+//
+// Matches NotFound.
+func (e *NotFoundError) Is(target error) bool {
+	return target == NotFound
+}
+
+//
+// Unique (or primary key) constraint violated.
+type UniqueViolation struct {
+	// Model kind.
+	Kind string
+	// Constrained fields.
+	Fields []string
+}
+
+//
+// Error description.
+func (e *UniqueViolation) Error() string {
+	return fmt.Sprintf(
+		"%s unique constraint violated: (%s)",
+		e.Kind,
+		strings.Join(e.Fields, ", "))
+}
+
+//
+// Foreign key constraint violated.
+// The Field and Table are not known when the violation
+// is caused by deleting a referenced model.
+type FkViolation struct {
+	// Model kind.
+	Kind string
+	// The FK field.
+	Field string
+	// The referenced table.
+	Table string
+}
+
+//
+// Error description.
+func (e *FkViolation) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf(
+			"%s foreign key constraint violated.",
+			e.Kind)
+	}
+	return fmt.Sprintf(
+		"%s foreign key constraint violated: %s references %s.",
+		e.Kind,
+		e.Field,
+		e.Table)
+}
+
+//
+// Not-null constraint violated.
+type NotNullViolation struct {
+	// Model kind.
+	Kind string
+	// The field.
+	Field string
+}
+
+//
+// Error description.
+func (e *NotNullViolation) Error() string {
+	return fmt.Sprintf(
+		"%s not-null constraint violated: %s",
+		e.Kind,
+		e.Field)
+}
+
+//
+// Build a NotFoundError for the model.
+func notFound(md *Definition) error {
+	err := &NotFoundError{Kind: md.Kind}
+	pk := md.PkField()
+	if pk != nil {
+		err.Pk = fmt.Sprintf("%v", pk.Value.Interface())
+	}
+
+	return err
+}
+
+//
+// Get whether the error is a (sqlite) constraint violation
+// of the primary key or a unique index.
+func isUniqueErr(err error) bool {
+	sql3Err := sqlite3.Error{}
+	if errors.As(err, &sql3Err) {
+		switch sql3Err.ExtendedCode {
+		case sqlite3.ErrConstraintPrimaryKey,
+			sqlite3.ErrConstraintUnique:
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// Translate (sqlite) errors into typed errors.
+// Errors not translated are returned as-is.
+func (t Table) translate(md *Definition, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(md)
+	}
+	sql3Err := sqlite3.Error{}
+	if !errors.As(err, &sql3Err) {
+		return err
+	}
+	switch sql3Err.ExtendedCode {
+	case sqlite3.ErrConstraintPrimaryKey,
+		sqlite3.ErrConstraintUnique:
+		return &UniqueViolation{
+			Kind:   md.Kind,
+			Fields: t.constrained(sql3Err),
+		}
+	case sqlite3.ErrConstraintNotNull:
+		violation := &NotNullViolation{Kind: md.Kind}
+		fields := t.constrained(sql3Err)
+		if len(fields) > 0 {
+			violation.Field = fields[0]
+		}
+		return violation
+	case sqlite3.ErrConstraintForeignKey:
+		return t.fkViolation(md)
+	}
+
+	return err
+}
+
+//
+// Fields reported by a constraint error.
+// Format: "<constraint> failed: <table>.<field>, ..."
+func (t Table) constrained(err sqlite3.Error) (fields []string) {
+	fields = []string{}
+	msg := err.Error()
+	n := strings.Index(msg, ": ")
+	if n == -1 {
+		return
+	}
+	for _, name := range strings.Split(msg[n+2:], ",") {
+		name = strings.TrimSpace(name)
+		part := strings.SplitN(name, ".", 2)
+		fields = append(fields, part[len(part)-1])
+	}
+
+	return
+}
+
+//
+// Build the FK violation.
+// The referenced table is probed for each FK to find the
+// first one not satisfied.
+func (t Table) fkViolation(md *Definition) (violation *FkViolation) {
+	violation = &FkViolation{Kind: md.Kind}
+	type FK struct {
+		field *Field
+		table string
+		to    string
+	}
+	fks := []FK{}
+	cursor, err := t.DB.Query("PRAGMA foreign_key_list(" + md.Kind + ")")
+	if err != nil {
+		log.V(4).Info(
+			"table: fk list failed.",
+			"error",
+			err.Error())
+		return
+	}
+	for cursor.Next() {
+		var id, seq int
+		var table, from, to, onUpdate, onDelete, match string
+		err = cursor.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match)
+		if err != nil {
+			break
+		}
+		f := md.Field(from)
+		if f == nil {
+			continue
+		}
+		fks = append(fks, FK{field: f, table: table, to: to})
+	}
+	_ = cursor.Close()
+	for _, fk := range fks {
+		stmt := fmt.Sprintf(
+			"SELECT COUNT(*) FROM %s WHERE %s = ?",
+			fk.table,
+			fk.to)
+		n := int64(0)
+		err = t.DB.QueryRow(stmt, fk.field.Value.Interface()).Scan(&n)
+		if err != nil {
+			err = liberr.Wrap(err, "sql", stmt)
+			log.V(4).Info(
+				"table: fk probe failed.",
+				"error",
+				err.Error())
+			continue
+		}
+		if n == 0 {
+			violation.Field = fk.field.Name
+			violation.Table = fk.table
+			break
+		}
+	}
+
+	return
+}
@@ -0,0 +1,518 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	liberr "github.com/konveyor/controller/pkg/error"
+	fb "github.com/konveyor/controller/pkg/filebacked"
+	"github.com/konveyor/controller/pkg/ref"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//
+// The max number of events retained by the (journal)
+// event log used to resume watches. 0 = disabled.
+// Example:
+//   func init() {
+//     model.EventLogLimit = 100000
+//   }
+var EventLogLimit = 10000
+
+//
+// The number of events per event log segment.
+// The oldest segment is discarded as a unit when
+// the limit has been exceeded.
+var EventLogSegment = 1000
+
+//
+// Errors.
+var (
+	// Resume not possible.
+	ResumeTooOldErr = errors.New("too old, resync")
+)
+
+//
+// Event log.
+// A bounded, file-backed log of committed events keyed
+// by event ID. Used to replay events missed by watches
+// being resumed. Segments are named (persistent) lists in
+// the log directory and are reloaded when the DB is opened.
+// Segments are saved when full and when the log is closed.
+// The models are encoded using the field codecs so encrypted
+// fields are stored encrypted. Event IDs are reserved (durably)
+// ahead of the events logged. After a crash, the serial number
+// is seeded past the reserved IDs so IDs are not reissued and
+// resuming before the reserved IDs fails with ResumeTooOldErr.
+type EventLog struct {
+	// Directory.
+	dir string
+	// Data model.
+	dm *DataModel
+	// Key provider.
+	keys KeyProvider
+	// Next segment number.
+	next int
+	// Segments (oldest first).
+	segments []*logSegment
+	// The ID of the last event logged.
+	last uint64
+	// The ID of the last event discarded.
+	discarded uint64
+	// The highest event ID (durably) reserved.
+	reserved uint64
+	// Number of events retained.
+	count int
+}
+
+//
+// Event log entry.
+// The (encoded) event stored in segments.
+type logEntry struct {
+	// Event ID.
+	ID uint64
+	// Transaction labels.
+	Labels []string
+	// Action.
+	Action uint8
+	// Model kind.
+	Kind string
+	// The encoded event subject.
+	Model string
+	// The encoded updated model.
+	Updated string
+	// The model labels.
+	ModelLabels Labels
+	// The model labels before the update.
+	PriorLabels Labels
+}
+
+//
+// Populate the entry using the event.
+// See: encodeModel().
+func (r *logEntry) With(event *Event, keys KeyProvider) (err error) {
+	*r = logEntry{
+		ID:          event.ID,
+		Labels:      event.Labels,
+		Action:      event.Action,
+		Kind:        ref.ToKind(event.Model),
+		ModelLabels: event.ModelLabels,
+		PriorLabels: event.PriorLabels,
+	}
+	r.Model, err = encodeModel(keys, event.Model, r.aad("Model"))
+	if err != nil {
+		return
+	}
+	if event.Action == Updated {
+		r.Updated, err = encodeModel(keys, event.Updated, r.aad("Updated"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Build the event.
+// See: decodeModel().
+func (r *logEntry) event(dm *DataModel, keys KeyProvider) (event Event, err error) {
+	md, found := dm.Find(r.Kind)
+	if !found {
+		err = liberr.New("kind not found.", "kind", r.Kind)
+		return
+	}
+	event = Event{
+		ID:          r.ID,
+		Labels:      r.Labels,
+		Action:      r.Action,
+		ModelLabels: r.ModelLabels,
+		PriorLabels: r.PriorLabels,
+	}
+	event.Model, err = decodeModel(md, keys, r.Model, r.aad("Model"))
+	if err != nil {
+		return
+	}
+	if r.Action == Updated {
+		event.Updated, err = decodeModel(md, keys, r.Updated, r.aad("Updated"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//
+// AAD used to encrypt the model fields.
+// Bound to the event, the role (Model|Updated) and the field.
+func (r *logEntry) aad(role string) func(*Field) string {
+	return func(f *Field) string {
+		return aad("EventLog", fmt.Sprint(r.ID), r.Kind+"."+role+"."+f.Name)
+	}
+}
+
+//
+// Event log segment.
+type logSegment struct {
+	// File path.
+	path string
+	// Events.
+	list *fb.List
+	// The ID of the last event.
+	last uint64
+	// Number of events.
+	count int
+}
+
+//
+// Append committed (staged) events.
+// Returns: the ID of the last event.
+func (r *EventLog) append(staged *fb.List) (last uint64) {
+	itr := staged.Iter()
+	defer itr.Close()
+	for {
+		event := Event{}
+		if !event.next(itr) {
+			break
+		}
+		last = event.ID
+		if EventLogLimit < 1 {
+			continue
+		}
+		r.reserve(event.ID)
+		entry := logEntry{}
+		err := entry.With(&event, r.keys)
+		if err != nil {
+			log.Error(err, "event log: encode event failed.")
+			r.discarded = event.ID
+			r.last = event.ID
+			continue
+		}
+		segment := r.current()
+		segment.list.Append(entry)
+		segment.last = event.ID
+		segment.count++
+		r.last = event.ID
+		r.count++
+	}
+	for r.count > EventLogLimit && len(r.segments) > 1 {
+		oldest := r.segments[0]
+		r.segments = r.segments[1:]
+		r.discarded = oldest.last
+		r.count -= oldest.count
+		r.discard(oldest)
+	}
+
+	return
+}
+
+//
+// Events committed after the specified event ID.
+// Returns ResumeTooOldErr when the events are no longer
+// retained or the ID is not known.
+func (r *EventLog) since(id uint64) (itr fb.Iterator, err error) {
+	if EventLogLimit < 1 || id < r.discarded || id > r.last {
+		err = liberr.Wrap(
+			ResumeTooOldErr,
+			"id",
+			id,
+			"last",
+			r.last)
+		return
+	}
+	list := fb.NewList()
+	for _, segment := range r.segments {
+		if segment.last <= id {
+			continue
+		}
+		sItr := segment.list.Iter()
+		for {
+			entry := logEntry{}
+			if !sItr.NextWith(&entry) {
+				break
+			}
+			if entry.ID <= id {
+				continue
+			}
+			var event Event
+			event, err = entry.event(r.dm, r.keys)
+			if err != nil {
+				sItr.Close()
+				list.Close()
+				return
+			}
+			event.append(list)
+		}
+		sItr.Close()
+	}
+
+	itr = list.Iter()
+
+	return
+}
+
+//
+// The current segment.
+// A new segment is created as needed and the
+// (full) current segment is saved.
+func (r *EventLog) current() (segment *logSegment) {
+	n := len(r.segments)
+	if n > 0 {
+		segment = r.segments[n-1]
+		if segment.count < EventLogSegment {
+			return
+		}
+		r.save(segment)
+	}
+	r.next++
+	segment = &logSegment{}
+	if r.dir == "" {
+		segment.list = fb.NewList()
+	} else {
+		var err error
+		segment.path = filepath.Join(
+			r.dir,
+			fmt.Sprintf("%.10d%s", r.next, fb.Extension))
+		segment.list, err = fb.OpenList(segment.path)
+		if err != nil {
+			log.Error(err, "event log: open segment failed.")
+			segment.path = ""
+			segment.list = fb.NewList()
+		}
+	}
+	r.segments = append(r.segments, segment)
+	return
+}
+
+//
+// Open the log.
+// Segments in the directory are reloaded. The entry
+// type is registered so the segments may be read.
+func (r *EventLog) open(dir string, dm *DataModel, keys KeyProvider) (err error) {
+	*r = EventLog{
+		dm:   dm,
+		keys: keys,
+	}
+	if EventLogLimit < 1 {
+		return
+	}
+	et := reflect.TypeOf(logEntry{})
+	fb.Register(et.PkgPath()+"."+et.Name(), logEntry{})
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		err = liberr.Wrap(err, "path", dir)
+		return
+	}
+	r.dir = dir
+	r.discarded, err = r.readMark(r.discardedPath())
+	if err != nil {
+		return
+	}
+	r.reserved, err = r.readMark(r.reservedPath())
+	if err != nil {
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+fb.Extension))
+	if err != nil {
+		err = liberr.Wrap(err, "path", dir)
+		return
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), fb.Extension)
+		n, pErr := strconv.Atoi(name)
+		if pErr != nil {
+			continue
+		}
+		segment := &logSegment{path: path}
+		segment.list, err = fb.OpenList(path)
+		if err != nil {
+			return
+		}
+		itr := segment.list.Iter()
+		for {
+			entry := logEntry{}
+			if !itr.NextWith(&entry) {
+				break
+			}
+			segment.last = entry.ID
+			segment.count++
+		}
+		itr.Close()
+		if segment.count == 0 {
+			r.discard(segment)
+			continue
+		}
+		r.segments = append(r.segments, segment)
+		r.last = segment.last
+		r.count += segment.count
+		r.next = n
+	}
+	if r.reserved > r.last {
+		r.recover()
+	}
+	serial.seed(1, r.last)
+
+	log.V(3).Info(
+		"event log: opened.",
+		"dir",
+		dir,
+		"count",
+		r.count,
+		"last",
+		r.last)
+
+	return
+}
+
+//
+// Recover after a crash.
+// Events logged after the last saved event (up to the
+// reserved ID) have been lost. The segments are discarded
+// and the log continues after the reserved IDs.
+func (r *EventLog) recover() {
+	log.Info(
+		"event log: recovered.",
+		"last",
+		r.last,
+		"reserved",
+		r.reserved)
+	r.last = r.reserved
+	r.discarded = r.reserved
+	for _, segment := range r.segments {
+		r.discard(segment)
+	}
+	r.segments = nil
+	r.count = 0
+}
+
+//
+// Reserve event IDs.
+// The reserved ID is (durably) advanced by a segment
+// ahead of the event ID as needed.
+func (r *EventLog) reserve(id uint64) {
+	if id < r.reserved || r.dir == "" {
+		return
+	}
+	r.reserved = id + uint64(EventLogSegment)
+	err := r.writeMark(r.reservedPath(), r.reserved)
+	if err != nil {
+		log.Error(err, "event log: write reserved failed.")
+	}
+}
+
+//
+// Save the segment.
+func (r *EventLog) save(segment *logSegment) {
+	err := segment.list.Flush()
+	if err != nil {
+		log.Error(err, "event log: save segment failed.")
+	}
+}
+
+//
+// Discard the segment.
+// Named segments are deleted and the ID of
+// the last event discarded is recorded.
+func (r *EventLog) discard(segment *logSegment) {
+	segment.list.Close()
+	if segment.path == "" {
+		return
+	}
+	_ = os.Remove(segment.path)
+	_ = os.Remove(segment.path + fb.IndexExtension)
+	err := r.writeMark(r.discardedPath(), r.discarded)
+	if err != nil {
+		log.Error(err, "event log: write discarded failed.")
+	}
+}
+
+//
+// Read an event ID (mark) file.
+// Returns: 0 when not found.
+func (r *EventLog) readMark(path string) (id uint64, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = liberr.Wrap(err, "path", path)
+		}
+		return
+	}
+	s := strings.TrimSpace(string(b))
+	if s != "" {
+		id, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			err = liberr.Wrap(err, "path", path)
+		}
+	}
+
+	return
+}
+
+//
+// Write an event ID (mark) file.
+// The file is synced before it is (atomically) replaced.
+func (r *EventLog) writeMark(path string, id uint64) (err error) {
+	tmp := path + ".tmp"
+	fp, err := os.Create(tmp)
+	if err != nil {
+		err = liberr.Wrap(err, "path", tmp)
+		return
+	}
+	_, err = fmt.Fprintf(fp, "%d\n", id)
+	if err == nil {
+		err = fp.Sync()
+	}
+	cErr := fp.Close()
+	if err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		err = liberr.Wrap(err, "path", path)
+	}
+
+	return
+}
+
+//
+// Path of the file recording the ID of the
+// last event discarded.
+func (r *EventLog) discardedPath() string {
+	return filepath.Join(r.dir, "discarded")
+}
+
+//
+// Path of the file recording the highest
+// event ID reserved.
+func (r *EventLog) reservedPath() string {
+	return filepath.Join(r.dir, "reserved")
+}
+
+//
+// Close the log.
+// Named segments are saved and the reserved
+// IDs not used are released.
+func (r *EventLog) close() {
+	for _, segment := range r.segments {
+		segment.list.Close()
+	}
+	if r.dir != "" && r.reserved > r.last {
+		r.reserved = r.last
+		err := r.writeMark(r.reservedPath(), r.reserved)
+		if err != nil {
+			log.Error(err, "event log: write reserved failed.")
+		}
+	}
+
+	r.segments = nil
+	r.count = 0
+}
@@ -0,0 +1,248 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"strings"
+	"text/template"
+)
+
+//
+// Label facet field prefix.
+// Facets of `label:` are the label names.
+// Facets of `label:<name>` are the values of the named label.
+const LabelFacet = "label:"
+
+//
+// Errors.
+var (
+	// Field not supported.
+	FacetFieldErr = errors.New("facet field must be (int, str, bool), not encrypted or compressed and within the detail level")
+	// Sort position not valid.
+	FacetSortErr = errors.New("facet sort position must be 1 (value) or 2 (count)")
+)
+
+//
+// Distinct (column) SQL.
+var DistinctSQL = `
+SELECT
+{{ .Field.Name }}
+,COUNT(*)
+FROM {{ .Table }}
+{{ if .Predicate -}}
+WHERE
+{{ .Predicate.Expr }}
+{{ end -}}
+GROUP BY 1
+ORDER BY
+{{ if .Sort -}}
+{{ range $i,$n := .Sort -}}
+{{ if $i }},{{ end }}{{ $n }}
+{{ end -}}
+{{ else -}}
+1
+{{ end -}}
+{{ if .Page -}}
+LIMIT {{.Page.Limit}} OFFSET {{.Page.Offset}}
+{{ end -}}
+;
+`
+
+//
+// Distinct (label) SQL.
+var LabelDistinctSQL = `
+SELECT
+{{ if .Label }}value{{ else }}name{{ end }}
+,COUNT(*)
+FROM Label
+WHERE
+kind = '{{ .Table }}'
+{{ if .Label -}}
+AND name = {{ .Label }}
+{{ end -}}
+{{ if .Predicate -}}
+AND parent IN
+(
+SELECT {{ .Pk.Name }}
+FROM {{ .Table }}
+WHERE
+{{ .Predicate.Expr }}
+)
+{{ end -}}
+GROUP BY 1
+ORDER BY
+{{ if .Sort -}}
+{{ range $i,$n := .Sort -}}
+{{ if $i }},{{ end }}{{ $n }}
+{{ end -}}
+{{ else -}}
+1
+{{ end -}}
+{{ if .Page -}}
+LIMIT {{.Page.Limit}} OFFSET {{.Page.Offset}}
+{{ end -}}
+;
+`
+
+//
+// Facet.
+// A distinct value and the number of models.
+type Facet struct {
+	// Distinct value.
+	Value interface{}
+	// Number of models.
+	Count int64
+}
+
+//
+// Facet template data.
+type FacetTmplData struct {
+	// Table name.
+	Table string
+	// Field.
+	Field *Field
+	// Label name (param).
+	Label string
+	// Primary key.
+	Pk *Field
+	// Filter options.
+	Options *FilterOptions
+}
+
+//
+// Predicate.
+func (t FacetTmplData) Predicate() Predicate {
+	return t.Options.Predicate
+}
+
+//
+// Pagination.
+func (t FacetTmplData) Page() *Page {
+	return t.Options.Page
+}
+
+//
+// Sort criteria.
+func (t FacetTmplData) Sort() []int {
+	return t.Options.Sort
+}
+
+//
+// Distinct values of a field with the number of models.
+// Qualified by the options predicate. The field may be `label:`
+// for label names or `label:<name>` for label values.
+// Fields must be within the options detail level. The options
+// sort is the position of the value (1) and count (2) and the
+// options page is applied to the facets.
+func (t Table) Distinct(model interface{}, field string, options FilterOptions) (list []Facet, err error) {
+	md, err := Inspect(model)
+	if err != nil {
+		return
+	}
+	for _, n := range options.Sort {
+		if n != 1 && n != 2 {
+			err = liberr.Wrap(FacetSortErr, "position", n)
+			return
+		}
+	}
+	err = options.Build(md)
+	if err != nil {
+		return
+	}
+	data := FacetTmplData{
+		Table:   md.Kind,
+		Pk:      md.PkField(),
+		Options: &options,
+	}
+	var stmt string
+	label := strings.HasPrefix(field, LabelFacet)
+	if label {
+		name := field[len(LabelFacet):]
+		if name != "" {
+			data.Label = options.Param("label", name)
+		}
+		stmt, err = t.facetSQL(LabelDistinctSQL, data)
+	} else {
+		f := md.Field(field)
+		if f == nil {
+			err = liberr.Wrap(PredicateRefErr, "field", field)
+			return
+		}
+		if f.Encoded() ||
+			f.Encrypted() ||
+			f.Compressed() ||
+			!f.MatchDetail(options.Detail) {
+			err = liberr.Wrap(FacetFieldErr, "field", field)
+			return
+		}
+		data.Field = f
+		stmt, err = t.facetSQL(DistinctSQL, data)
+	}
+	if err != nil {
+		return
+	}
+	params := options.Params()
+	cursor, err := t.DB.Query(stmt, params...)
+	if err != nil {
+		err = liberr.Wrap(err, "sql", stmt, "params", params)
+		return
+	}
+	defer func() {
+		_ = cursor.Close()
+	}()
+	list = []Facet{}
+	for cursor.Next() {
+		facet := Facet{}
+		if label {
+			value := ""
+			err = cursor.Scan(&value, &facet.Count)
+			facet.Value = value
+		} else {
+			mDef, _ := Inspect(md.NewModel())
+			f := mDef.Field(field)
+			err = cursor.Scan(f.Ptr(), &facet.Count)
+			if err == nil {
+				err = f.Push(t.Keys, "")
+			}
+			facet.Value = f.Value.Interface()
+		}
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		list = append(list, facet)
+	}
+
+	log.V(5).Info(
+		"table: distinct succeeded.",
+		"sql",
+		stmt,
+		"params",
+		params,
+		"matched",
+		len(list))
+
+	return
+}
+
+//
+// Build facet SQL.
+func (t Table) facetSQL(tplSQL string, data FacetTmplData) (sql string, err error) {
+	tpl := template.New("")
+	tpl, err = tpl.Parse(tplSQL)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(bfr, data)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	sql = bfr.String()
+
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	liberr "github.com/konveyor/controller/pkg/error"
 	"github.com/pkg/errors"
+	"math"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -62,6 +63,11 @@ type Field struct {
 	string string
 	// Staging (int) values.
 	int int64
+	// Staging (raw) values.
+	// Compressed and encrypted fields are scanned raw so the
+	// storage type (BLOB=compressed|encrypted, TEXT=legacy)
+	// can be determined.
+	raw interface{}
 	// Referenced as a parameter.
 	isParam bool
 }
@@ -87,15 +93,156 @@ func (f *Field) Validate() error {
 	if f.Detail() > MaxDetail {
 		return liberr.Wrap(DetailErr)
 	}
+	if f.Encrypted() {
+		if f.Pk() || f.Key() || len(f.Unique()) > 0 || len(f.Index()) > 0 {
+			return liberr.Wrap(EncryptedErr, "field", f.Name)
+		}
+		switch f.Value.Kind() {
+		case reflect.String,
+			reflect.Struct,
+			reflect.Slice,
+			reflect.Map:
+		default:
+			return liberr.Wrap(EncryptedErr, "field", f.Name)
+		}
+	}
+	if f.TTL() {
+		switch f.Value.Kind() {
+		case reflect.Int,
+			reflect.Int32,
+			reflect.Int64:
+		default:
+			return liberr.Wrap(TTLErr, "field", f.Name)
+		}
+		if f.Pk() {
+			return liberr.Wrap(TTLErr, "field", f.Name)
+		}
+	}
+	if f.Compressed() {
+		if f.Pk() || f.Key() || len(f.Unique()) > 0 || len(f.Index()) > 0 {
+			return liberr.Wrap(CompressedErr, "field", f.Name)
+		}
+		switch f.Value.Kind() {
+		case reflect.String,
+			reflect.Struct,
+			reflect.Slice,
+			reflect.Map:
+		default:
+			return liberr.Wrap(CompressedErr, "field", f.Name)
+		}
+	}
 
 	return nil
 }
 
+//
+// Pull from model.
+// Populate the appropriate `staging` field using the
+// model field value. Compressed fields are compressed
+// and encrypted fields are encrypted using the key provider
+// and AAD. Both are stored as BLOB.
+// Returns: the value to be stored.
+func (f *Field) Pull(keys KeyProvider, aad string) (value interface{}, err error) {
+	value = f.pull()
+	if f.Compressed() || f.Encrypted() {
+		value, err = f.encode(keys, aad, f.string)
+	}
+
+	return
+}
+
+//
+// Encode the (plain) value to be stored.
+// Compressed then encrypted. Encrypted compressed values
+// are encrypted using the compressed AAD.
+func (f *Field) encode(keys KeyProvider, aad, plain string) (value interface{}, err error) {
+	f.string = plain
+	if f.Compressed() {
+		f.string, err = compress(f.string)
+		if err != nil {
+			err = liberr.Wrap(err, "field", f.Name)
+			return
+		}
+		aad = zipped(aad)
+	}
+	if f.Encrypted() {
+		f.string, err = encrypt(keys, f.string, aad)
+		if err != nil {
+			err = liberr.Wrap(err, "field", f.Name)
+			return
+		}
+	}
+
+	value = []byte(f.string)
+
+	return
+}
+
+//
+// Decode the stored value.
+// Values stored as TEXT are (legacy) plain values. Values
+// stored as BLOB are decrypted and decompressed. An encrypted
+// field may contain values encrypted before the field was
+// compressed (detected by the AAD) or compressed before the
+// field was encrypted (detected by the missing prefix).
+// Returns: the plain value and whether it was compressed.
+func (f *Field) decode(keys KeyProvider, aad, stored string, blob bool) (plain string, compressed bool, err error) {
+	plain = stored
+	if !blob {
+		return
+	}
+	compressed = f.Compressed()
+	encrypted := f.Encrypted()
+	if encrypted && compressed && !strings.HasPrefix(stored, EncPrefix) {
+		encrypted = false
+	}
+	if encrypted {
+		if compressed {
+			plain, err = decrypt(keys, stored, zipped(aad))
+			if errors.Is(err, CipherTextErr) {
+				compressed = false
+				plain, err = decrypt(keys, stored, aad)
+			}
+		} else {
+			plain, err = decrypt(keys, stored, aad)
+		}
+		if err != nil {
+			err = liberr.Wrap(err, "field", f.Name)
+			return
+		}
+	}
+	if compressed {
+		plain, err = decompress(plain)
+		if err != nil {
+			err = liberr.Wrap(err, "field", f.Name)
+			return
+		}
+	}
+
+	return
+}
+
+//
+// Staged (scanned) value as a string.
+// Used to build the AAD before the model is updated.
+func (f *Field) staged() string {
+	switch f.Value.Kind() {
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		return strconv.FormatInt(f.int, 10)
+	default:
+		return f.string
+	}
+}
+
 //
 // Pull from model.
 // Populate the appropriate `staging` field using the
 // model field value.
-func (f *Field) Pull() interface{} {
+func (f *Field) pull() interface{} {
 	switch f.Value.Kind() {
 	case reflect.Struct:
 		object := f.Value.Interface()
@@ -153,6 +300,9 @@ func (f *Field) Pull() interface{} {
 //
 // Pointer used for Scan().
 func (f *Field) Ptr() interface{} {
+	if f.Compressed() || f.Encrypted() {
+		return &f.raw
+	}
 	switch f.Value.Kind() {
 	case reflect.Bool,
 		reflect.Int,
@@ -169,7 +319,36 @@ func (f *Field) Ptr() interface{} {
 //
 // Push to the model.
 // Set the model field value using the `staging` field.
-func (f *Field) Push() {
+// Compressed and encrypted fields stored as BLOB are decoded
+// using the key provider and AAD; stored as TEXT are (legacy)
+// plain values. The model field is not changed when an error
+// is returned.
+func (f *Field) Push(keys KeyProvider, aad string) (err error) {
+	if f.Compressed() || f.Encrypted() {
+		stored := ""
+		blob := false
+		switch raw := f.raw.(type) {
+		case []byte:
+			stored = string(raw)
+			blob = true
+		case string:
+			stored = raw
+		}
+		f.string, _, err = f.decode(keys, aad, stored, blob)
+		if err != nil {
+			return
+		}
+	}
+
+	f.push()
+
+	return
+}
+
+//
+// Push to the model.
+// Set the model field value using the `staging` field.
+func (f *Field) push() {
 	switch f.Value.Kind() {
 	case reflect.Struct:
 		if len(f.string) == 0 {
@@ -309,6 +488,28 @@ func (f *Field) Virtual() bool {
 	return f.hasOpt("virtual")
 }
 
+//
+// Get whether field is encrypted.
+// An `encrypt` field is encrypted at rest.
+func (f *Field) Encrypted() bool {
+	return f.hasOpt("encrypt")
+}
+
+//
+// Get whether field is compressed.
+// A `compress` field is compressed at rest.
+func (f *Field) Compressed() bool {
+	return f.hasOpt("compress")
+}
+
+//
+// Get whether field is the TTL (expires-at).
+// The value is the time (unix seconds) at which the model
+// expires and is reaped. Zero(0) = never.
+func (f *Field) TTL() bool {
+	return f.hasOpt("ttl")
+}
+
 //
 // Get whether the field is unique.
 func (f *Field) Unique() []string {
@@ -410,7 +611,18 @@ func (f *Field) AsValue(object interface{}) (value interface{}, err error) {
 			reflect.Int32,
 			reflect.Int64:
 			n := val.Int()
-			value = strconv.FormatInt(n, 0)
+			value = strconv.FormatInt(n, 10)
+		case reflect.Uint,
+			reflect.Uint8,
+			reflect.Uint16,
+			reflect.Uint32,
+			reflect.Uint64:
+			n := val.Uint()
+			value = strconv.FormatUint(n, 10)
+		case reflect.Float32,
+			reflect.Float64:
+			n := val.Float()
+			value = strconv.FormatFloat(n, 'g', -1, 64)
 		default:
 			err = liberr.Wrap(PredicateValueErr)
 		}
@@ -437,6 +649,15 @@ func (f *Field) AsValue(object interface{}) (value interface{}, err error) {
 			} else {
 				value = false
 			}
+		case reflect.Uint,
+			reflect.Uint8,
+			reflect.Uint16,
+			reflect.Uint32,
+			reflect.Uint64:
+			value = val.Uint() != 0
+		case reflect.Float32,
+			reflect.Float64:
+			value = val.Float() != 0
 		default:
 			err = liberr.Wrap(PredicateValueErr)
 		}
@@ -464,6 +685,29 @@ func (f *Field) AsValue(object interface{}) (value interface{}, err error) {
 			reflect.Int32,
 			reflect.Int64:
 			value = val.Int()
+		case reflect.Uint,
+			reflect.Uint8,
+			reflect.Uint16,
+			reflect.Uint32,
+			reflect.Uint64:
+			n := val.Uint()
+			if n > math.MaxInt64 {
+				err = liberr.Wrap(PredicateValueErr)
+				return
+			}
+			value = int64(n)
+		case reflect.Float32,
+			reflect.Float64:
+			value = val.Float()
+		default:
+			err = liberr.Wrap(PredicateValueErr)
+		}
+	case reflect.Struct,
+		reflect.Slice,
+		reflect.Map:
+		switch val.Kind() {
+		case reflect.String:
+			value = val.String()
 		default:
 			err = liberr.Wrap(PredicateValueErr)
 		}
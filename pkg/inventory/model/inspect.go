@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	liberr "github.com/konveyor/controller/pkg/error"
 	fb "github.com/konveyor/controller/pkg/filebacked"
 	"reflect"
@@ -32,6 +33,18 @@ type Definition struct {
 	model  interface{}
 }
 
+//
+// AAD used to encrypt the field.
+// See: aad().
+func (r *Definition) aad(f *Field) string {
+	pk := ""
+	if pkField := r.PkField(); pkField != nil {
+		pk = fmt.Sprint(pkField.Value.Interface())
+	}
+
+	return aad(r.Kind, pk, f.Name)
+}
+
 //
 // Get the mutable `Fields` for the model.
 func (r *Definition) MutableFields() []*Field {
@@ -97,6 +110,18 @@ func (r *Definition) PkField() *Field {
 	return nil
 }
 
+//
+// Get the TTL (expires-at) field.
+func (r *Definition) TTLField() *Field {
+	for _, f := range r.Fields {
+		if f.TTL() {
+			return f
+		}
+	}
+
+	return nil
+}
+
 //
 // Field by name.
 func (r *Definition) Field(name string) *Field {
@@ -139,6 +164,31 @@ func (r *Definition) validate() (err error) {
 	pk := r.PkField()
 	if pk == nil {
 		err = liberr.Wrap(MustHavePkErr)
+		return
+	}
+	nTTL := 0
+	for _, f := range r.Fields {
+		if f.TTL() {
+			nTTL++
+		}
+	}
+	if nTTL > 1 {
+		err = liberr.Wrap(TTLErr, "kind", r.Kind)
+		return
+	}
+	withFields := pk.WithFields()
+	for _, f := range r.Fields {
+		if !withFields[strings.ToLower(f.Name)] {
+			continue
+		}
+		if f.Encrypted() {
+			err = liberr.Wrap(EncryptedErr, "field", f.Name)
+			return
+		}
+		if f.Compressed() {
+			err = liberr.Wrap(CompressedErr, "field", f.Name)
+			return
+		}
 	}
 
 	return
@@ -230,7 +280,8 @@ func (r *Definition) NewModel() (m interface{}) {
 // New data Model.
 func NewModel(models []interface{}) (dm *DataModel, err error) {
 	dm = &DataModel{
-		content: make(map[string]*Definition),
+		content:  make(map[string]*Definition),
+		internal: make(map[string]bool),
 	}
 	for _, m := range models {
 		var md *Definition
@@ -249,6 +300,8 @@ func NewModel(models []interface{}) (dm *DataModel, err error) {
 // Map of definitions.
 type DataModel struct {
 	content map[string]*Definition
+	// Internal kinds.
+	internal map[string]bool
 }
 
 //
@@ -258,6 +311,30 @@ func (r *DataModel) Add(md *Definition) {
 	r.content[key] = md
 }
 
+//
+// Add internal models.
+// Internal kinds (labels, outbox) are managed by the DB
+// and are not watched.
+func (r *DataModel) AddInternal(models ...interface{}) (err error) {
+	for _, m := range models {
+		var md *Definition
+		md, err = Inspect(m)
+		if err != nil {
+			return
+		}
+		r.Add(md)
+		r.internal[strings.ToLower(md.Kind)] = true
+	}
+
+	return
+}
+
+//
+// The kind is internal.
+func (r *DataModel) Internal(md *Definition) bool {
+	return r.internal[strings.ToLower(md.Kind)]
+}
+
 //
 // Definitions.
 func (r *DataModel) Definitions() (list Definitions) {
@@ -323,7 +400,7 @@ func (r *DataModel) cascade(tx *Tx, relation *FkRelation, md *Definition) (casca
 	list := fb.NewList()
 	referencing := relation.Referencing(md)
 	pk := md.PkField()
-	pkID := pk.Pull()
+	pkID := pk.pull()
 	for _, ref := range referencing {
 		if !ref.cascade {
 			continue
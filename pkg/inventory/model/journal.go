@@ -1,13 +1,40 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"github.com/go-logr/logr"
 	liberr "github.com/konveyor/controller/pkg/error"
 	fb "github.com/konveyor/controller/pkg/filebacked"
 	"github.com/konveyor/controller/pkg/logging"
 	"github.com/konveyor/controller/pkg/ref"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+)
+
+//
+// Errors.
+var (
+	// Watch kind not specified.
+	WatchKindErr = errors.New("watch must specify a model or kinds")
+	// Event handler panic.
+	HandlerPanicErr = errors.New("event handler panic")
+)
+
+//
+// Handler panic policy.
+type PanicPolicy uint8
+
+//
+// Handler panic policies.
+const (
+	// The watch is terminated.
+	TerminateOnPanic PanicPolicy = iota
+	// The watch continues with the next event.
+	ContinueOnPanic
 )
 
 //
@@ -39,6 +66,13 @@ type Event struct {
 	Action uint8
 	// The updated model.
 	Updated Model
+	// The model labels (as recorded).
+	ModelLabels Labels
+	// The model labels before the update (Updated events).
+	PriorLabels Labels
+	// Field changes (Updated events).
+	// See: WatchOptions.Diff.
+	Changes []Change
 }
 
 //
@@ -56,7 +90,21 @@ func (r *Event) HasLabel(label string) bool {
 //
 // String representation.
 func (r *Event) String() string {
-	action := "unknown"
+	model := ""
+	if r.Model != nil {
+		model = Describe(r.Model)
+	}
+	return fmt.Sprintf(
+		"event-%.4d: %s model=%s",
+		r.ID,
+		r.action(),
+		model)
+}
+
+//
+// Action name.
+func (r *Event) action() (action string) {
+	action = "unknown"
 	switch r.Action {
 	case Parity:
 		action = "parity"
@@ -71,15 +119,8 @@ func (r *Event) String() string {
 	case Deleted:
 		action = "deleted"
 	}
-	model := ""
-	if r.Model != nil {
-		model = Describe(r.Model)
-	}
-	return fmt.Sprintf(
-		"event-%.4d: %s model=%s",
-		r.ID,
-		action,
-		model)
+
+	return
 }
 
 //
@@ -90,9 +131,11 @@ func (r *Event) String() string {
 //   Event.Updated (optional)
 func (r *Event) append(list *fb.List) {
 	list.Append(Event{
-		ID:     r.ID,
-		Labels: r.Labels,
-		Action: r.Action,
+		ID:          r.ID,
+		Labels:      r.Labels,
+		Action:      r.Action,
+		ModelLabels: r.ModelLabels,
+		PriorLabels: r.PriorLabels,
 	})
 	list.Append(r.Model)
 	if r.Action == Updated {
@@ -137,6 +180,53 @@ type WatchOptions struct {
 	// Initial snapshot.
 	// List models and report as `Created` events.
 	Snapshot bool
+	// Resume after the specified event ID.
+	// Events committed after the event are replayed (instead
+	// of the snapshot) when still retained by the event log.
+	// Else, the watch fails with ResumeTooOldErr and the
+	// client must resync. 0 = not resumed.
+	ResumeFrom uint64
+	// Predicate.
+	// Only events for models matching the predicate are
+	// reported. Updated events are reported when both the
+	// model and the updated model match. Reported as Deleted
+	// when only the model matches and as Created when only
+	// the updated model matches. Must implement Matcher.
+	// Also applied to the snapshot.
+	Predicate Predicate
+	// Backpressure policy.
+	// Applied when the watch queue is full.
+	Policy Backpressure
+	// The time the committer is blocked by the BlockPolicy.
+	// 0 = WatchBlockTimeout.
+	Timeout time.Duration
+	// Coalesce window.
+	// Events for the same model (PK) are merged into one net
+	// event delivered per window. Created then Deleted cancel
+	// out and Updates are collapsed into one with the original
+	// model. 0 = disabled.
+	Coalesce time.Duration
+	// Kinds (models) watched in addition to the watch model.
+	// Events for all watched kinds are delivered in commit
+	// order. Parity is reported after all kinds have been
+	// snapshotted.
+	Kinds []Model
+	// Watch all kinds.
+	AllKinds bool
+	// Transaction labels included.
+	// Only events for transactions with at least one of
+	// the labels are reported.
+	IncludeLabels []string
+	// Transaction labels excluded.
+	// Events for transactions with any of the labels
+	// are not reported.
+	ExcludeLabels []string
+	// Compute the field changes for Updated events.
+	// See: Event.Changes.
+	Diff bool
+	// Handler panic policy.
+	// Panics are recovered and reported to Handler.Error().
+	OnPanic PanicPolicy
 }
 
 //
@@ -162,17 +252,67 @@ type EventHandler interface {
 	End()
 }
 
+//
+// Parity (boundary) handler.
+// Optional event handler method called (instead of
+// Parity) with the ID of the last event included in the
+// snapshot (boundary). Only events after the boundary
+// are delivered.
+type ParityHandler interface {
+	ParityAt(id uint64)
+}
+
 //
 // Model event watch.
 type Watch struct {
 	// Model to be watched.
+	// May be nil when watching kinds.
 	Model Model
 	// Event handler.
 	Handler EventHandler
 	// ID
 	id uint64
+	// Mutex.
+	mutex sync.Mutex
 	// Event queue.
 	queue chan fb.Iterator
+	// Closed when the watch has been ended.
+	// The queue is drained and not closed so
+	// committers never send on a closed channel.
+	ended chan struct{}
+	// Overflow queue.
+	overflow overflow
+	// Backpressure policy.
+	policy Backpressure
+	// Block timeout.
+	timeout time.Duration
+	// Coalesce window.
+	window time.Duration
+	// Statistics.
+	stats WatchStats
+	// Events replayed (resumed).
+	replay fb.Iterator
+	// Predicate (built).
+	predicate Predicate
+	// Watched kinds.
+	kinds map[string]bool
+	// Watch all kinds.
+	all bool
+	// Transaction labels included.
+	include []string
+	// Transaction labels excluded.
+	exclude []string
+	// Compute field changes.
+	diff bool
+	// The ID of the last event reported when the
+	// watch was registered (snapshot boundary).
+	boundary uint64
+	// Handler panic policy.
+	onPanic PanicPolicy
+	// Halted by a handler panic.
+	halted bool
+	// Last error reported to the handler.
+	lastErr error
 	// Journal.
 	journal *Journal
 	// Logger.
@@ -186,13 +326,37 @@ type Watch struct {
 //
 // String representation.
 func (w *Watch) String() string {
-	kind := ref.ToKind(w.Model)
+	kind := "*"
+	if !w.all {
+		kind = strings.Join(w.Kinds(), ",")
+	}
 	return fmt.Sprintf(
 		"watch-%.4d: model=%s",
 		w.id,
 		kind)
 }
 
+//
+// The snapshot boundary.
+// The ID of the last event reported when the watch
+// was registered.
+func (w *Watch) Boundary() uint64 {
+	return w.boundary
+}
+
+//
+// Watched kinds (sorted).
+// Empty when watching all kinds.
+func (w *Watch) Kinds() (list []string) {
+	for kind := range w.kinds {
+		list = append(list, kind)
+	}
+
+	sort.Strings(list)
+
+	return
+}
+
 //
 // End the watch.
 func (w *Watch) End() {
@@ -202,100 +366,510 @@ func (w *Watch) End() {
 //
 // The watch has not ended.
 func (w *Watch) Alive() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
 	return !w.done
 }
 
 //
 // Match by model `kind`.
 func (w *Watch) Match(model Model) bool {
-	return ref.ToKind(w.Model) == ref.ToKind(model)
+	return w.all || w.kinds[ref.ToKind(model)]
+}
+
+//
+// Match by transaction labels.
+func (w *Watch) MatchLabels(labels []string) bool {
+	has := func(list []string) bool {
+		for _, l := range list {
+			for _, label := range labels {
+				if l == label {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if has(w.exclude) {
+		return false
+	}
+	if len(w.include) > 0 {
+		return has(w.include)
+	}
+
+	return true
+}
+
+//
+// Match the event using the predicate.
+// Updated events are matched using both the model and
+// the updated model (and their labels). When only the
+// model matches, the model has left the predicate and
+// the event is delivered as Deleted. When only the
+// updated model matches, the model has entered the
+// predicate and the event is delivered as Created.
+func (w *Watch) filter(event *Event) (matched bool, err error) {
+	if w.predicate == nil {
+		matched = true
+		return
+	}
+	matcher, cast := w.predicate.(Matcher)
+	if !cast {
+		err = liberr.Wrap(MatcherErr)
+		return
+	}
+	match := func(m Model, labels Labels) (matched bool, err error) {
+		md, err := Inspect(m)
+		if err != nil {
+			return
+		}
+		matched, err = matcher.Match(md, labels)
+		return
+	}
+	if event.Action != Updated {
+		matched, err = match(event.Model, event.ModelLabels)
+		return
+	}
+	before, err := match(event.Model, event.PriorLabels)
+	if err != nil {
+		return
+	}
+	after, err := match(event.Updated, event.ModelLabels)
+	if err != nil {
+		return
+	}
+	switch {
+	case before && after:
+		matched = true
+	case before:
+		matched = true
+		event.Action = Deleted
+		event.ModelLabels = event.PriorLabels
+		event.Updated = nil
+	case after:
+		matched = true
+		event.Action = Created
+		event.Model = event.Updated
+		event.Updated = nil
+	}
+
+	return
+}
+
+//
+// Watch statistics.
+func (w *Watch) Stats() (stats WatchStats) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	stats = w.stats
+	stats.Queued = len(w.queue)
+	stats.Overflow = w.overflow.Len()
+	stats.Coalesced += w.overflow.merged
+	return
 }
 
 //
 // Queue event.
-func (w *Watch) notify(itr fb.Iterator) {
+// The backpressure policy is applied when the queue is full.
+// The watch may be ended concurrently.
+// Returns: false when the watch must be terminated.
+func (w *Watch) notify(itr fb.Iterator) (alive bool) {
+	alive = true
 	defer func() {
-		recover()
+		if p := recover(); p != nil {
+			itr.Close()
+			w.log.V(3).Info(
+				"watch ended, event discarded.",
+				"recovered",
+				p)
+		}
 	}()
+	switch w.policy {
+	case BlockPolicy:
+		alive = w.block(itr)
+	case SpillPolicy,
+		CoalescePolicy:
+		w.enqueue(itr)
+	case TerminatePolicy:
+		select {
+		case w.queue <- itr:
+		case <-w.ended:
+			itr.Close()
+		default:
+			alive = false
+		}
+	default:
+		select {
+		case w.queue <- itr:
+		case <-w.ended:
+			itr.Close()
+		default:
+			itr.Close()
+			w.mutex.Lock()
+			w.stats.Dropped++
+			w.mutex.Unlock()
+			description := "full queue, event discarded"
+			w.error(liberr.New(description))
+			w.log.V(3).Info(description)
+		}
+	}
+	if !alive {
+		itr.Close()
+		w.error(
+			liberr.Wrap(
+				BackpressureErr,
+				"watch",
+				w.String()))
+		w.log.V(3).Info("full queue, watch terminated.")
+	}
+
+	return
+}
+
+//
+// Queue event.
+// The committer is blocked until queued or the
+// timeout has expired.
+// Returns: false when the timeout has expired.
+func (w *Watch) block(itr fb.Iterator) (queued bool) {
 	select {
 	case w.queue <- itr:
+		queued = true
+		return
+	case <-w.ended:
+		itr.Close()
+		queued = true
+		return
 	default:
-		description := "full queue, event discarded"
-		w.Handler.Error(liberr.New(description))
-		w.log.V(3).Info(description)
 	}
+	mark := time.Now()
+	select {
+	case w.queue <- itr:
+		queued = true
+	case <-w.ended:
+		itr.Close()
+		queued = true
+	case <-time.After(w.timeout):
+	}
+	w.mutex.Lock()
+	w.stats.Blocked += time.Since(mark)
+	w.mutex.Unlock()
+	return
+}
+
+//
+// Queue event.
+// Added to the overflow when the queue is full or the
+// overflow has pending events.
+func (w *Watch) enqueue(itr fb.Iterator) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.done {
+		itr.Close()
+		return
+	}
+	if w.overflow.Len() == 0 {
+		select {
+		case w.queue <- itr:
+			return
+		case <-w.ended:
+			itr.Close()
+			return
+		default:
+		}
+	}
+	w.overflow.add(itr)
+}
+
+//
+// Dequeue the overflow.
+// Taken only when the queue is empty to preserve order.
+// Returns: nil when nothing to dequeue.
+func (w *Watch) dequeue() (itr fb.Iterator) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.queue) == 0 && w.overflow.Len() > 0 {
+		itr = w.overflow.take()
+	}
+	return
 }
 
 //
 // Run the watch.
 // Forward events to the `handler`.
 func (w *Watch) Start(snapshot fb.Iterator) {
+	w.mutex.Lock()
 	if w.started {
+		w.mutex.Unlock()
 		return
 	}
+	w.started = true
+	w.mutex.Unlock()
 	w.log.V(3).Info("watch started.")
-	w.Handler.Started(w.id)
+	w.call(func() {
+		w.Handler.Started(w.id)
+	})
 	run := func() {
 		defer func() {
+			if w.halted {
+				w.journal.End(w)
+			}
+			w.mutex.Lock()
+			w.overflow.reset()
 			w.started = false
 			w.done = true
-			w.Handler.End()
+			w.mutex.Unlock()
+			w.drain(nil)
+			w.call(w.Handler.End)
 			w.log.V(3).Info("watch stopped.")
 		}()
-		for {
+		for !w.halted {
 			m, hasNext := snapshot.Next()
 			if hasNext {
-				w.Handler.Created(
-					Event{
-						Action: Created,
-						Model:  m.(Model),
-					})
+				w.call(func() {
+					w.Handler.Created(
+						Event{
+							Action: Created,
+							Model:  m.(Model),
+						})
+				})
 			} else {
-				w.log.V(3).Info("has parity.")
-				w.Handler.Parity()
 				break
 			}
 		}
-		for itr := range w.queue {
+		if w.replay != nil {
+			w.log.V(3).Info(
+				"replay events.",
+				"count",
+				w.replay.Len())
+			w.forward(w.replay)
+			w.replay = nil
+		}
+		if w.halted {
+			return
+		}
+		w.log.V(3).Info(
+			"has parity.",
+			"boundary",
+			w.boundary)
+		w.call(func() {
+			if handler, cast := w.Handler.(ParityHandler); cast {
+				handler.ParityAt(w.boundary)
+			} else {
+				w.Handler.Parity()
+			}
+		})
+		if w.window > 0 {
+			w.debounce()
+			return
+		}
+		for {
+			select {
+			case itr := <-w.queue:
+				w.forward(itr)
+				if w.halted {
+					return
+				}
+				for {
+					itr = w.dequeue()
+					if itr == nil {
+						break
+					}
+					w.forward(itr)
+				}
+			case <-w.ended:
+				w.drain(w.forward)
+				return
+			}
+		}
+	}
+
+	go run()
+}
+
+//
+// Drain queued events.
+// Passed to `fn` in order. Closed when `fn` is nil.
+func (w *Watch) drain(fn func(fb.Iterator)) {
+	if fn == nil {
+		fn = func(itr fb.Iterator) {
+			itr.Close()
+		}
+	}
+	for {
+		var itr fb.Iterator
+		select {
+		case itr = <-w.queue:
+		default:
+			itr = w.dequeue()
+		}
+		if itr == nil {
+			return
+		}
+		fn(itr)
+	}
+}
+
+//
+// Forward coalesced events to the handler.
+// Events are merged and forwarded once per window.
+// Pending events are forwarded when the watch ends.
+func (w *Watch) debounce() {
+	pending := overflow{policy: CoalescePolicy}
+	flush := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		w.mutex.Lock()
+		w.stats.Coalesced += pending.merged
+		w.mutex.Unlock()
+		pending.merged = 0
+		w.forward(pending.take())
+	}
+	ticker := time.NewTicker(w.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ended:
+			w.drain(pending.add)
+			flush()
+			return
+		case itr := <-w.queue:
+			pending.add(itr)
 			for {
-				event := Event{}
-				hasNext := event.next(itr)
-				if !hasNext {
+				itr = w.dequeue()
+				if itr == nil {
 					break
 				}
-				if !w.Match(event.Model) {
-					continue
-				}
-				w.log.V(5).Info(
-					"event received.",
-					"event",
-					event.String())
-				switch event.Action {
-				case Created:
-					w.Handler.Created(event)
-				case Updated:
-					w.Handler.Updated(event)
-				case Deleted:
-					w.Handler.Deleted(event)
-				default:
-					w.log.Info(
-						"unknown action.",
-						"event",
-						event.String())
+				pending.add(itr)
+			}
+		case <-ticker.C:
+			flush()
+		}
+		if w.halted {
+			return
+		}
+	}
+}
+
+//
+// Forward events to the handler.
+func (w *Watch) forward(itr fb.Iterator) {
+	defer itr.Close()
+	for !w.halted {
+		event := Event{}
+		hasNext := event.next(itr)
+		if !hasNext {
+			break
+		}
+		if !w.Match(event.Model) || !w.MatchLabels(event.Labels) {
+			continue
+		}
+		matched, err := w.filter(&event)
+		if err != nil {
+			w.error(err)
+			w.log.V(3).Info(
+				"predicate failed.",
+				"event",
+				event.String(),
+				"error",
+				err.Error())
+			continue
+		}
+		if !matched {
+			continue
+		}
+		w.log.V(5).Info(
+			"event received.",
+			"event",
+			event.String())
+		w.mutex.Lock()
+		w.stats.Delivered++
+		w.mutex.Unlock()
+		switch event.Action {
+		case Created:
+			w.call(func() {
+				w.Handler.Created(event)
+			})
+		case Updated:
+			if w.diff {
+				event.Changes, err = Diff(event.Model, event.Updated)
+				if err != nil {
+					w.error(err)
 				}
 			}
+			w.call(func() {
+				w.Handler.Updated(event)
+			})
+		case Deleted:
+			w.call(func() {
+				w.Handler.Deleted(event)
+			})
+		default:
+			w.log.Info(
+				"unknown action.",
+				"event",
+				event.String())
 		}
 	}
+}
 
-	w.started = true
-	go run()
+//
+// Call the handler.
+// A panic is recovered and reported to Handler.Error() with
+// the stack. The watch is halted (and terminated) based on
+// the panic policy.
+func (w *Watch) call(fn func()) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		err := liberr.Wrap(
+			HandlerPanicErr,
+			"watch",
+			w.String(),
+			"panic",
+			p,
+			"stack",
+			string(debug.Stack()))
+		w.log.Error(err, "handler panic recovered.")
+		w.mutex.Lock()
+		w.stats.Panics++
+		w.mutex.Unlock()
+		if w.onPanic == TerminateOnPanic {
+			w.halted = true
+		}
+		w.error(err)
+	}()
+	fn()
+}
+
+//
+// Report an error to the handler.
+// The error is recorded as the last error.
+func (w *Watch) error(err error) {
+	w.mutex.Lock()
+	w.lastErr = err
+	w.mutex.Unlock()
+	defer func() {
+		if p := recover(); p != nil {
+			w.log.Info(
+				"handler panic (error) recovered.",
+				"panic",
+				p)
+		}
+	}()
+	w.Handler.Error(err)
 }
 
 //
 // Terminate.
 func (w *Watch) terminate() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
 	if w.started {
-		close(w.queue)
+		close(w.ended)
 	}
 }
 
@@ -304,34 +878,82 @@ func (w *Watch) terminate() {
 // Provides model watch events.
 type Journal struct {
 	mutex sync.RWMutex
+	// Commit mutex.
+	// Held while transactions are committed and reported.
+	commit sync.Mutex
 	// Logger.
 	log logr.Logger
 	// List of registered watches.
 	watches []*Watch
+	// List of registered (CDC) exporters.
+	exporters []*Exporter
+	// Log of committed events.
+	events EventLog
+	// The ID of the last event reported.
+	reported uint64
 }
 
 //
 // Watch a `watch` of the specified model.
 // The returned watch has not been started.
+// When resumed, the events to be replayed are captured.
 // See: Watch.Start().
 func (r *Journal) Watch(model Model, handler EventHandler) (*Watch, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	id := serial.next(0)
-	log := logging.WithName("journal|watch").WithValues(
+	var replay fb.Iterator
+	options := handler.Options()
+	kinds := []Model{}
+	if model != nil {
+		kinds = append(kinds, model)
+	}
+	kinds = append(kinds, options.Kinds...)
+	if len(kinds) == 0 && !options.AllKinds {
+		return nil, liberr.Wrap(WatchKindErr)
+	}
+	err := buildPredicate(options.Predicate, kinds)
+	if err != nil {
+		return nil, err
+	}
+	if options.ResumeFrom > 0 {
+		replay, err = r.events.since(options.ResumeFrom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	watch := &Watch{
+		Handler:   handler,
+		Model:     model,
+		id:        serial.next(0),
+		replay:    replay,
+		predicate: options.Predicate,
+		kinds:     make(map[string]bool),
+		all:       options.AllKinds,
+		include:   options.IncludeLabels,
+		exclude:   options.ExcludeLabels,
+		diff:      options.Diff,
+		onPanic:   options.OnPanic,
+		policy:    options.Policy,
+		timeout:   options.Timeout,
+		window:    options.Coalesce,
+		boundary:  r.reported,
+		journal:   r,
+	}
+	for _, m := range kinds {
+		watch.kinds[ref.ToKind(m)] = true
+	}
+	watch.log = logging.WithName("journal|watch").WithValues(
 		"id",
-		id,
+		watch.id,
 		"model",
-		ref.ToKind(model))
-	watch := &Watch{
-		Handler: handler,
-		Model:   model,
-		id:      id,
-		journal: r,
-		log:     log,
+		strings.Join(watch.Kinds(), ","))
+	if watch.timeout == 0 {
+		watch.timeout = WatchBlockTimeout
 	}
+	watch.overflow.policy = options.Policy
 	r.watches = append(r.watches, watch)
-	watch.queue = make(chan fb.Iterator, 250)
+	watch.queue = make(chan fb.Iterator, WatchQueueSize)
+	watch.ended = make(chan struct{})
 
 	r.log.V(3).Info(
 		"watch created.",
@@ -341,11 +963,34 @@ func (r *Journal) Watch(model Model, handler EventHandler) (*Watch, error) {
 	return watch, nil
 }
 
+//
+// Open the journal.
+// The event log is (re)loaded.
+func (r *Journal) open(dir string, dm *DataModel, keys KeyProvider) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err = r.events.open(dir, dm, keys)
+	if err != nil {
+		return
+	}
+
+	r.reported = r.events.last
+
+	return
+}
+
 //
 // End watch.
 func (r *Journal) End(watch *Watch) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.end(watch)
+}
+
+//
+// End watch.
+// The mutex must be held.
+func (r *Journal) end(watch *Watch) {
 	kept := []*Watch{}
 	for _, w := range r.watches {
 		if w != watch {
@@ -362,30 +1007,188 @@ func (r *Journal) End(watch *Watch) {
 	r.watches = kept
 }
 
+//
+// Watch status.
+type WatchStatus struct {
+	// Watch ID.
+	ID uint64
+	// Watched kinds.
+	// Empty when watching all kinds.
+	Kinds []string
+	// Statistics.
+	WatchStats
+	// Last error reported to the handler.
+	LastError error
+}
+
+//
+// Status of registered watches.
+func (r *Journal) Status() (list []WatchStatus) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	list = []WatchStatus{}
+	for _, w := range r.watches {
+		stats := w.Stats()
+		w.mutex.Lock()
+		lastErr := w.lastErr
+		w.mutex.Unlock()
+		list = append(
+			list,
+			WatchStatus{
+				ID:         w.id,
+				Kinds:      w.Kinds(),
+				WatchStats: stats,
+				LastError:  lastErr,
+			})
+	}
+
+	return
+}
+
+//
+// Register a (CDC) exporter.
+// The checkpoint is loaded and committed events
+// are exported to the sink.
+func (r *Journal) Export(exporter *Exporter) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	exporter.start()
+	r.exporters = append(r.exporters, exporter)
+
+	r.log.V(3).Info(
+		"exporter registered.",
+		"checkpoint",
+		exporter.Checkpoint)
+
+	return
+}
+
+//
+// End (unregister) an exporter.
+// The exporter is stopped and the sink is closed. The
+// exporter is closed without holding the mutex because
+// the export goroutine may be trimming the outbox.
+func (r *Journal) EndExport(exporter *Exporter) {
+	r.mutex.Lock()
+	kept := []*Exporter{}
+	ended := []*Exporter{}
+	for _, x := range r.exporters {
+		if x != exporter {
+			kept = append(kept, x)
+		} else {
+			ended = append(ended, x)
+		}
+	}
+	r.exporters = kept
+	r.mutex.Unlock()
+	for _, x := range ended {
+		x.close()
+		r.log.V(3).Info(
+			"exporter ended.",
+			"checkpoint",
+			exporter.Checkpoint)
+	}
+}
+
 //
 // Transaction committed.
-// Recorded (staged) events are forwarded to watches.
+// Recorded (staged) events are logged and forwarded to
+// watches. Exporters are notified to drain the outbox.
+// The mutex is released before the events are forwarded
+// so the committer is not blocked (BlockPolicy) while
+// holding it. Transactions are reported in commit order
+// (the commit mutex is held by the committer).
 func (r *Journal) Report(staged *fb.List) {
 	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	last := r.events.append(staged)
+	if last > r.reported {
+		r.reported = last
+	}
+	for _, x := range r.exporters {
+		x.notify()
+	}
+	labels := r.labels(staged)
+	watches := []*Watch{}
 	for _, w := range r.watches {
-		w.notify(staged.Iter())
+		if w.MatchLabels(labels) {
+			watches = append(watches, w)
+		}
+	}
+	r.mutex.Unlock()
+	terminated := []*Watch{}
+	for _, w := range watches {
+		if !w.notify(staged.Iter()) {
+			terminated = append(terminated, w)
+		}
+	}
+	if len(terminated) > 0 {
+		r.mutex.Lock()
+		for _, w := range terminated {
+			r.end(w)
+		}
+		r.mutex.Unlock()
 	}
 }
 
 //
 // Close the journal.
-// End all watches.
+// End all watches and exporters.
 func (r *Journal) Close() (err error) {
 	for _, w := range r.watches {
 		r.End(w)
 	}
+	r.mutex.RLock()
+	exporters := r.exporters
+	r.mutex.RUnlock()
+	for _, x := range exporters {
+		r.EndExport(x)
+	}
+	r.mutex.Lock()
+	r.events.close()
+	r.mutex.Unlock()
 
 	r.log.V(3).Info("journal closed.")
 
 	return
 }
 
+//
+// Transaction labels of the (staged) events.
+func (r *Journal) labels(staged *fb.List) []string {
+	itr := staged.Iter()
+	defer itr.Close()
+	event := Event{}
+	event.next(itr)
+	return event.Labels
+}
+
+//
+// Build (validate) the predicate for each kind.
+// The predicate must implement Matcher.
+func buildPredicate(predicate Predicate, kinds []Model) (err error) {
+	if predicate == nil {
+		return
+	}
+	if _, cast := predicate.(Matcher); !cast {
+		err = liberr.Wrap(MatcherErr)
+		return
+	}
+	for _, m := range kinds {
+		var md *Definition
+		md, err = Inspect(m)
+		if err != nil {
+			return
+		}
+		filter := FilterOptions{Predicate: predicate}
+		err = filter.Build(md)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
 //
 // Model is being watched.
 // Determine if there a watch interested in the model.
@@ -457,3 +1260,17 @@ func (r *Serial) next(key int) (sn uint64) {
 	r.pool[key] = sn
 	return
 }
+
+//
+// Seed the serial number.
+// Ensures the next serial number is greater than `sn`.
+func (r *Serial) seed(key int, sn uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.pool == nil {
+		r.pool = make(map[int]uint64)
+	}
+	if r.pool[key] < sn {
+		r.pool[key] = sn
+	}
+}
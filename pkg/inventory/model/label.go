@@ -37,3 +37,14 @@ func (l *Label) Equals(other Model) bool {
 func (l *Label) Labels() Labels {
 	return nil
 }
+
+//
+// Labels of the model.
+// Returns nil when the model is not Labeled.
+func labels(model Model) Labels {
+	if labeled, cast := model.(Labeled); cast {
+		return labeled.Labels()
+	}
+
+	return nil
+}
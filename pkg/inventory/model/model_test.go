@@ -1,12 +1,20 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	liberr "github.com/konveyor/controller/pkg/error"
 	"github.com/konveyor/controller/pkg/ref"
 	"github.com/onsi/gomega"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -51,6 +59,46 @@ func (m *PlainObject) Labels() Labels {
 	return nil
 }
 
+type SecretObject struct {
+	ID       int         `sql:"pk"`
+	Name     string      `sql:""`
+	Password string      `sql:"encrypt"`
+	Object   TestEncoded `sql:"encrypt"`
+}
+
+func (m *SecretObject) Pk() string {
+	return fmt.Sprintf("%d", m.ID)
+}
+
+type CompressedObject struct {
+	ID     int         `sql:"pk"`
+	Name   string      `sql:""`
+	Text   string      `sql:"compress"`
+	Object TestEncoded `sql:"compress"`
+}
+
+func (m *CompressedObject) Pk() string {
+	return fmt.Sprintf("%d", m.ID)
+}
+
+type UniqueObject struct {
+	ID   int    `sql:"pk"`
+	Name string `sql:"unique(a)"`
+}
+
+func (m *UniqueObject) Pk() string {
+	return fmt.Sprintf("%d", m.ID)
+}
+
+type ExpiringObject struct {
+	ID      int   `sql:"pk"`
+	Expires int64 `sql:"ttl,index(expires)"`
+}
+
+func (m *ExpiringObject) Pk() string {
+	return fmt.Sprintf("%d", m.ID)
+}
+
 type DetailA struct {
 	PK int `sql:"pk"`
 	FK int `sql:"fk(PlainObject +cascade +must)"`
@@ -132,7 +180,68 @@ type TestEvent struct {
 	updated *TestObject
 }
 
+//
+// Handler state shared with the watch goroutine.
+// Updated and read while holding the mutex.
+type Synced struct {
+	mutex   sync.Mutex
+	changed chan struct{}
+}
+
+//
+// Update the state and signal the waiter.
+func (s *Synced) update(fn func()) {
+	s.mutex.Lock()
+	fn()
+	changed := s.signal()
+	s.mutex.Unlock()
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+//
+// Read the state.
+func (s *Synced) read(fn func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fn()
+}
+
+//
+// Wait for the condition evaluated while holding the mutex.
+// Returns: false when not met within 5 seconds.
+func (s *Synced) wait(fn func() bool) bool {
+	timeout := time.After(time.Second * 5)
+	for {
+		s.mutex.Lock()
+		met := fn()
+		changed := s.signal()
+		s.mutex.Unlock()
+		if met {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+//
+// The signal channel.
+// The mutex must be held.
+func (s *Synced) signal() chan struct{} {
+	if s.changed == nil {
+		s.changed = make(chan struct{}, 1)
+	}
+	return s.changed
+}
+
 type TestHandler struct {
+	Synced
 	options WatchOptions
 	name    string
 	started bool
@@ -150,46 +259,61 @@ func (w *TestHandler) Options() WatchOptions {
 }
 
 func (w *TestHandler) Started(uint64) {
-	w.started = true
+	w.update(func() {
+		w.started = true
+	})
 }
 
 func (w *TestHandler) Parity() {
-	w.parity = true
+	w.update(func() {
+		w.parity = true
+	})
 }
 
 func (w *TestHandler) Created(e Event) {
 	if object, cast := e.Model.(*TestObject); cast {
-		w.all = append(w.all, TestEvent{action: e.Action, model: object})
-		w.created = append(w.created, object.ID)
+		w.update(func() {
+			w.all = append(w.all, TestEvent{action: e.Action, model: object})
+			w.created = append(w.created, object.ID)
+		})
 	}
 }
 
 func (w *TestHandler) Updated(e Event) {
 	if object, cast := e.Model.(*TestObject); cast {
-		w.all = append(w.all, TestEvent{
-			action:  e.Action,
-			model:   object,
-			updated: e.Updated.(*TestObject),
+		w.update(func() {
+			w.all = append(w.all, TestEvent{
+				action:  e.Action,
+				model:   object,
+				updated: e.Updated.(*TestObject),
+			})
+			w.updated = append(w.updated, object.ID)
 		})
-		w.updated = append(w.updated, object.ID)
 	}
 }
 func (w *TestHandler) Deleted(e Event) {
 	if object, cast := e.Model.(*TestObject); cast {
-		w.all = append(w.all, TestEvent{action: e.Action, model: object})
-		w.deleted = append(w.deleted, object.ID)
+		w.update(func() {
+			w.all = append(w.all, TestEvent{action: e.Action, model: object})
+			w.deleted = append(w.deleted, object.ID)
+		})
 	}
 }
 
 func (w *TestHandler) Error(err error) {
-	w.err = append(w.err, err)
+	w.update(func() {
+		w.err = append(w.err, err)
+	})
 }
 
 func (w *TestHandler) End() {
-	w.done = true
+	w.update(func() {
+		w.done = true
+	})
 }
 
 type MutatingHandler struct {
+	Synced
 	options WatchOptions
 	DB
 	name    string
@@ -217,7 +341,9 @@ func (w *MutatingHandler) Created(e Event) {
 	e.Model.(*TestObject).Age++
 	_ = tx.Update(e.Model)
 	_ = tx.Commit()
-	w.created = append(w.created, e.Model.(*TestObject).ID)
+	w.update(func() {
+		w.created = append(w.created, e.Model.(*TestObject).ID)
+	})
 }
 
 func (w *MutatingHandler) Updated(e Event) {
@@ -231,7 +357,9 @@ func (w *MutatingHandler) Updated(e Event) {
 	e.Model.(*TestObject).Age++
 	_ = tx.Update(e.Model)
 	_ = tx.Commit()
-	w.updated = append(w.updated, e.Model.(*TestObject).ID)
+	w.update(func() {
+		w.updated = append(w.updated, e.Model.(*TestObject).ID)
+	})
 }
 
 func (w *MutatingHandler) Deleted(e Event) {
@@ -248,13 +376,16 @@ func (w *MutatingHandler) End() {
 // Used for cascade delete event testing.
 type DetailHandler struct {
 	StockEventHandler
+	Synced
 	deleted []string
 }
 
 func (h *DetailHandler) Deleted(e Event) {
-	h.deleted = append(
-		h.deleted,
-		e.Model.Pk())
+	h.update(func() {
+		h.deleted = append(
+			h.deleted,
+			e.Model.Pk())
+	})
 }
 
 func TestDefinition(t *testing.T) {
@@ -510,16 +641,66 @@ func TestCascade(t *testing.T) {
 	n, _ = DB.Count(&DetailC{}, nil)
 	g.Expect(n).To(gomega.Equal(int64(27)))
 
-	for i := 0; i < 10; i++ {
-		time.Sleep(time.Millisecond * 10)
-		if len(handler.deleted) != 40 {
-			continue
-		} else {
-			break
-		}
-	}
-	g.Expect(len(handler.deleted)).To(gomega.Equal(40))
+	handler.wait(func() bool { return len(handler.deleted) == 40 })
+	handler.read(func() {
+		g.Expect(len(handler.deleted)).To(gomega.Equal(40))
+	})
+
+}
 
+func TestConstraintErrors(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test-constraint-errors.db",
+		&PlainObject{},
+		&UniqueObject{},
+		&DetailA{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	// Not found.
+	err = DB.Get(&PlainObject{ID: 1})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	notFound := &NotFoundError{}
+	g.Expect(errors.As(err, &notFound)).To(gomega.BeTrue())
+	g.Expect(notFound.Kind).To(gomega.Equal("PlainObject"))
+	g.Expect(notFound.Pk).To(gomega.Equal("1"))
+	err = DB.Update(&PlainObject{ID: 1})
+	g.Expect(errors.As(err, &notFound)).To(gomega.BeTrue())
+	// Insert (upsert) by PK.
+	err = DB.Insert(&UniqueObject{ID: 1, Name: "A"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&UniqueObject{ID: 1, Name: "B"})
+	g.Expect(err).To(gomega.BeNil())
+	// Unique.
+	err = DB.Insert(&UniqueObject{ID: 2, Name: "B"})
+	unique := &UniqueViolation{}
+	g.Expect(errors.As(err, &unique)).To(gomega.BeTrue())
+	g.Expect(unique.Kind).To(gomega.Equal("UniqueObject"))
+	g.Expect(unique.Fields).To(gomega.Equal([]string{"Name"}))
+	err = DB.Insert(&UniqueObject{ID: 2, Name: "C"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&UniqueObject{ID: 2, Name: "B"})
+	g.Expect(errors.As(err, &unique)).To(gomega.BeTrue())
+	// FK.
+	err = DB.Insert(&DetailA{PK: 1, FK: 9})
+	fk := &FkViolation{}
+	g.Expect(errors.As(err, &fk)).To(gomega.BeTrue())
+	g.Expect(fk.Kind).To(gomega.Equal("DetailA"))
+	g.Expect(fk.Field).To(gomega.Equal("FK"))
+	g.Expect(fk.Table).To(gomega.Equal("PlainObject"))
+	// Not-null.
+	md, _ := Inspect(&PlainObject{})
+	_, err = DB.Execute("INSERT INTO PlainObject (ID) VALUES (9)")
+	g.Expect(err).ToNot(gomega.BeNil())
+	notNull := &NotNullViolation{}
+	err = Table{}.translate(md, err)
+	g.Expect(errors.As(err, &notNull)).To(gomega.BeTrue())
+	g.Expect(notNull.Kind).To(gomega.Equal("PlainObject"))
+	g.Expect(notNull.Field).To(gomega.Equal("Name"))
 }
 
 func TestTransactions(t *testing.T) {
@@ -612,6 +793,74 @@ func TestWithTxFailed(t *testing.T) {
 	g.Expect(n).To(gomega.Equal(int64(0)))
 }
 
+func TestSavepoint(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test-savepoint.db",
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	handler := &TestHandler{name: "A"}
+	w, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w).ToNot(gomega.BeNil())
+	insert := func(tx *Tx, id int) {
+		err := tx.Insert(&TestObject{ID: id, Name: "Elmer"})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = tx.End()
+	}()
+	insert(tx, 0)
+	// Invalid name.
+	err = tx.Savepoint("a;b")
+	g.Expect(errors.Is(err, SavepointNameErr)).To(gomega.BeTrue())
+	// Not found.
+	err = tx.RollbackTo("none")
+	g.Expect(errors.Is(err, SavepointNotFoundErr)).To(gomega.BeTrue())
+	// Rollback to.
+	err = tx.Savepoint("a")
+	g.Expect(err).To(gomega.BeNil())
+	insert(tx, 1)
+	err = tx.Savepoint("b")
+	g.Expect(err).To(gomega.BeNil())
+	insert(tx, 2)
+	err = tx.RollbackTo("a")
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Release("b")
+	g.Expect(errors.Is(err, SavepointNotFoundErr)).To(gomega.BeTrue())
+	insert(tx, 3)
+	// Release.
+	err = tx.Savepoint("c")
+	g.Expect(err).To(gomega.BeNil())
+	insert(tx, 4)
+	err = tx.Release("c")
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Release("a")
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	// Committed.
+	for _, id := range []int{0, 3, 4} {
+		err = DB.Get(&TestObject{ID: id})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	for _, id := range []int{1, 2} {
+		err = DB.Get(&TestObject{ID: id})
+		g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	}
+	// Reported.
+	handler.wait(func() bool { return len(handler.created) == 3 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{0, 3, 4}))
+	})
+}
+
 func TestList(t *testing.T) {
 	var err error
 	g := gomega.NewGomegaWithT(t)
@@ -917,6 +1166,219 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestStream(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test-stream.db",
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	N := 10
+	for i := 0; i < N; i++ {
+		object := &TestObject{
+			ID:     i,
+			Name:   "Elmer",
+			Age:    18,
+			Object: TestEncoded{Name: "json"},
+			D4:     "d-4",
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Stream all; detail level=0
+	cursor, err := DB.Stream(
+		&TestObject{},
+		ListOptions{
+			Sort: []int{2},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	ids := []int{}
+	for {
+		m, hasNext := cursor.Next()
+		if !hasNext {
+			break
+		}
+		object := m.(*TestObject)
+		g.Expect(object.Name).To(gomega.Equal(""))
+		ids = append(ids, object.ID)
+	}
+	g.Expect(cursor.Err()).To(gomega.BeNil())
+	g.Expect(cursor.Count()).To(gomega.Equal(N))
+	g.Expect(ids).To(gomega.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}))
+	// Stream with predicate; detail level=max.
+	// Closed before exhausted.
+	cursor, err = DB.Stream(
+		&TestObject{},
+		ListOptions{
+			Detail:    MaxDetail,
+			Predicate: Gt("ID", 6),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	object := TestObject{}
+	g.Expect(cursor.NextWith(&object)).To(gomega.BeTrue())
+	g.Expect(object.ID).To(gomega.Equal(7))
+	g.Expect(object.Name).To(gomega.Equal("Elmer"))
+	g.Expect(object.Object.Name).To(gomega.Equal("json"))
+	cursor.Close()
+	cursor.Close()
+	_, hasNext := cursor.Next()
+	g.Expect(hasNext).To(gomega.BeFalse())
+	// Sessions returned.
+	for i := 0; i < 20; i++ {
+		cursor, err = DB.Stream(&TestObject{}, ListOptions{})
+		g.Expect(err).To(gomega.BeNil())
+		cursor.Close()
+	}
+	// Invalid predicate.
+	_, err = DB.Stream(
+		&TestObject{},
+		ListOptions{
+			Predicate: Eq("Unknown", 0),
+		})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(N)))
+}
+
+func TestFacets(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test-facets.db",
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID:   i,
+			Name: fmt.Sprintf("n%d", i%3),
+			Bool: i%2 == 0,
+			labels: Labels{
+				"parity": fmt.Sprintf("%t", i%2 == 0),
+			},
+		}
+		if i < 4 {
+			object.labels["low"] = "true"
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Distinct field.
+	list, err := DB.Distinct(&TestObject{}, "Name", ListOptions{Detail: DefaultDetail})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "n0", Count: 4},
+			{Value: "n1", Count: 3},
+			{Value: "n2", Count: 3},
+		}))
+	list, err = DB.Distinct(
+		&TestObject{},
+		"bool",
+		ListOptions{
+			Detail:    DefaultDetail,
+			Predicate: Gt("ID", 6),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: false, Count: 2},
+			{Value: true, Count: 1},
+		}))
+	// Label names.
+	list, err = DB.Distinct(&TestObject{}, LabelFacet, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "low", Count: 4},
+			{Value: "parity", Count: 10},
+		}))
+	// Label values.
+	list, err = DB.Distinct(
+		&TestObject{},
+		LabelFacet+"parity",
+		ListOptions{Predicate: Lt("ID", 3)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "false", Count: 1},
+			{Value: "true", Count: 2},
+		}))
+	// Facets.
+	facets, err := DB.Facets(
+		&TestObject{},
+		[]string{"Name", LabelFacet + "low"},
+		ListOptions{
+			Detail:    DefaultDetail,
+			Predicate: Match(Labels{"parity": "true"}),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(facets).To(
+		gomega.Equal(map[string][]Facet{
+			"Name": {
+				{Value: "n0", Count: 2},
+				{Value: "n1", Count: 1},
+				{Value: "n2", Count: 2},
+			},
+			LabelFacet + "low": {
+				{Value: "true", Count: 2},
+			},
+		}))
+	// Sorted by count and paginated.
+	list, err = DB.Distinct(
+		&TestObject{},
+		"Name",
+		ListOptions{
+			Detail: DefaultDetail,
+			Sort:   []int{2, 1},
+			Page: &Page{
+				Offset: 1,
+				Limit:  1,
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "n2", Count: 3},
+		}))
+	list, err = DB.Distinct(
+		&TestObject{},
+		LabelFacet,
+		ListOptions{
+			Sort: []int{2},
+			Page: &Page{Limit: 1},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "low", Count: 4},
+		}))
+	_, err = DB.Distinct(&TestObject{}, "Name", ListOptions{Sort: []int{3}})
+	g.Expect(errors.Is(err, FacetSortErr)).To(gomega.BeTrue())
+	// Detail level.
+	list, err = DB.Distinct(&TestObject{}, "D4", ListOptions{Detail: 4})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Facet{
+			{Value: "", Count: 10},
+		}))
+	// Not supported.
+	_, err = DB.Distinct(&TestObject{}, "D4", ListOptions{Detail: 3})
+	g.Expect(errors.Is(err, FacetFieldErr)).To(gomega.BeTrue())
+	_, err = DB.Distinct(&TestObject{}, "Object", ListOptions{Detail: MaxDetail})
+	g.Expect(errors.Is(err, FacetFieldErr)).To(gomega.BeTrue())
+	_, err = DB.Distinct(&TestObject{}, "Unknown", ListOptions{})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+}
+
 func TestWatch(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New("/tmp/test-watch.db", &TestObject{})
@@ -993,21 +1455,20 @@ func TestWatch(t *testing.T) {
 		err = DB.Delete(object)
 		g.Expect(err).To(gomega.BeNil())
 	}
-	for i := 0; i < N; i++ {
-		time.Sleep(time.Millisecond * 10)
-		if len(handlerA.created) != N ||
-			len(handlerA.updated) != N ||
-			len(handlerA.created) != N ||
-			len(handlerB.created) != N ||
-			len(handlerB.updated) != N ||
-			len(handlerB.created) != N ||
-			len(handlerC.created) != N ||
-			len(handlerC.created) != N {
-			continue
-		} else {
-			break
-		}
+	for _, h := range []*TestHandler{handlerA, handlerB} {
+		h.wait(func() bool {
+			return len(h.created) == N &&
+				len(h.updated) == N &&
+				len(h.deleted) == N
+		})
 	}
+	handlerC.wait(func() bool {
+		return len(handlerC.created) == N &&
+			len(handlerC.deleted) == N
+	})
+	handlerD.wait(func() bool {
+		return len(handlerD.deleted) == N
+	})
 	g.Expect(handlerA.started).To(gomega.BeTrue())
 	g.Expect(handlerB.started).To(gomega.BeTrue())
 	g.Expect(handlerC.started).To(gomega.BeTrue())
@@ -1136,60 +1597,1483 @@ func TestWatch(t *testing.T) {
 	watchB.End()
 	watchC.End()
 	watchD.End()
-	ended := false
-	for i := 0; i < 10; i++ {
-		if watchA.started || watchB.started || watchC.started || watchD.started {
-			time.Sleep(50 * time.Millisecond)
-		} else {
-			ended = true
-			break
-		}
-	}
 	g.Expect(len(watchA.journal.watches)).To(gomega.Equal(0))
-	g.Expect(ended).To(gomega.BeTrue())
-	g.Expect(handlerA.done).To(gomega.BeTrue())
-	g.Expect(handlerB.done).To(gomega.BeTrue())
-	g.Expect(handlerC.done).To(gomega.BeTrue())
-	g.Expect(handlerD.done).To(gomega.BeTrue())
+	for _, h := range []*TestHandler{handlerA, handlerB, handlerC, handlerD} {
+		g.Expect(h.wait(func() bool { return h.done })).To(gomega.BeTrue())
+	}
+	for _, w := range []*Watch{watchA, watchB, watchC, watchD} {
+		g.Expect(w.Alive()).To(gomega.BeFalse())
+	}
 }
 
-func TestCloseDB(t *testing.T) {
+type TestSink struct {
+	mutex   sync.Mutex
+	records []Record
+	batch   int
+	fail    bool
+}
+
+func (r *TestSink) Write(records []Record) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.fail {
+		return liberr.New("faked")
+	}
+	if len(records) > r.batch {
+		r.batch = len(records)
+	}
+	r.records = append(r.records, records...)
+	return nil
+}
+
+func (r *TestSink) Records() []Record {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Record{}, r.records...)
+}
+
+func (r *TestSink) Fail(fail bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fail = fail
+}
+
+func (r *TestSink) Close() error {
+	return nil
+}
+
+func TestExport(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
-	DB := New("/tmp/test-close-db.db", &TestObject{})
-	err := DB.Open(true)
+	dir, err := ioutil.TempDir("", "test-export")
+	g.Expect(err).To(gomega.BeNil())
 	defer func() {
-		_ = DB.Close(false)
+		_ = os.RemoveAll(dir)
 	}()
+	// Wait for the outbox to be drained.
+	drained := func(DB DB) bool {
+		for i := 0; i < 100; i++ {
+			records, err := DB.(*Client).outbox(0, 100)
+			g.Expect(err).To(gomega.BeNil())
+			if len(records) == 0 {
+				return true
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		return false
+	}
+	checkpoint := filepath.Join(dir, "checkpoint")
+	path := filepath.Join(dir, "cdc", "events.json")
+	// Outbox required.
+	DB := New("/tmp/test-export.db", &TestObject{})
+	err = DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	handler := &TestHandler{
-		options: WatchOptions{Snapshot: true},
-		name:    "A",
+	err = DB.Export(&Exporter{Sink: &TestSink{}, Checkpoint: checkpoint})
+	g.Expect(errors.Is(err, ExportErr)).To(gomega.BeTrue())
+	_ = DB.Close(true)
+	DB = NewWith("/tmp/test-export.db", Settings{Export: true}, &TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Committed before the exporter is registered.
+	N := 10
+	for i := 0; i < N; i++ {
+		err = DB.Insert(&TestObject{ID: i, Name: "Elmer"})
+		g.Expect(err).To(gomega.BeNil())
 	}
-	watch, err := DB.Watch(&TestObject{}, handler)
-	for i := 0; i < 10; i++ {
-		if !watch.started {
-			time.Sleep(50 * time.Millisecond)
-		} else {
-			break
-		}
+	exporter := &Exporter{
+		Sink: &FileSink{
+			Path:     path,
+			MaxSize:  1024,
+			MaxFiles: 100,
+		},
+		Checkpoint: checkpoint,
+		Batch:      3,
 	}
-	g.Expect(handler.started).To(gomega.BeTrue())
-	g.Expect(handler.done).To(gomega.BeFalse())
+	err = DB.Export(exporter)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Name: "Fudd"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(drained(DB)).To(gomega.BeTrue())
+	last := exporter.Last()
+	b, err := ioutil.ReadFile(checkpoint)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(b)).To(gomega.Equal(fmt.Sprintf("%d\n", last)))
+	DB.EndExport(exporter)
 	_ = DB.Close(true)
-	for _, session := range DB.(*Client).pool.sessions {
-		g.Expect(session.closed).To(gomega.BeTrue())
+	// Read the (rotated) files.
+	matched, _ := filepath.Glob(path + "*")
+	g.Expect(len(matched) > 1).To(gomega.BeTrue())
+	n := func(p string) (n int) {
+		n, _ = strconv.Atoi(strings.TrimPrefix(filepath.Ext(p), "."))
+		return
 	}
-	for i := 0; i < 100; i++ {
-		if !watch.done {
-			time.Sleep(50 * time.Millisecond)
-		} else {
-			break
+	sort.Slice(
+		matched,
+		func(i, j int) bool {
+			return n(matched[i]) > n(matched[j])
+		})
+	type R struct {
+		ID      uint64
+		Action  string
+		Kind    string
+		Model   TestObject
+		Updated *TestObject
+	}
+	records := []R{}
+	for _, p := range matched {
+		b, err = ioutil.ReadFile(p)
+		g.Expect(err).To(gomega.BeNil())
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			r := R{}
+			err = json.Unmarshal([]byte(line), &r)
+			g.Expect(err).To(gomega.BeNil())
+			records = append(records, r)
 		}
 	}
-
-	g.Expect(handler.done).To(gomega.BeTrue())
-}
-
+	g.Expect(len(records)).To(gomega.Equal(N + 2))
+	for i, r := range records {
+		g.Expect(r.Kind).To(gomega.Equal("TestObject"))
+		if i > 0 {
+			g.Expect(r.ID > records[i-1].ID).To(gomega.BeTrue())
+		}
+	}
+	g.Expect(records[N].Action).To(gomega.Equal("updated"))
+	g.Expect(records[N].Model.Name).To(gomega.Equal("Elmer"))
+	g.Expect(records[N].Updated.Name).To(gomega.Equal("Fudd"))
+	g.Expect(records[N+1].Action).To(gomega.Equal("deleted"))
+	g.Expect(records[N+1].Model.ID).To(gomega.Equal(1))
+	g.Expect(records[N+1].ID).To(gomega.Equal(last))
+	// Restart (resumed), with sink failure.
+	DB = NewWith("/tmp/test-export.db", Settings{Export: true}, &TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	sink := &TestSink{fail: true}
+	exporter = &Exporter{
+		Sink:       sink,
+		Checkpoint: checkpoint,
+		Batch:      2,
+		Retry:      time.Millisecond * 10,
+	}
+	err = DB.Export(exporter)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(exporter.Last()).To(gomega.Equal(last))
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(drained(DB)).To(gomega.BeFalse())
+	g.Expect(exporter.Last()).To(gomega.Equal(last))
+	// Ended (unexported) and committed without an exporter.
+	DB.EndExport(exporter)
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 2})
+	g.Expect(err).To(gomega.BeNil())
+	_ = DB.Close(false)
+	DB = NewWith("/tmp/test-export.db", Settings{Export: true}, &TestObject{})
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	sink = &TestSink{}
+	exporter = &Exporter{
+		Sink:       sink,
+		Checkpoint: checkpoint,
+		Batch:      2,
+	}
+	err = DB.Export(exporter)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(drained(DB)).To(gomega.BeTrue())
+	exported := sink.Records()
+	g.Expect(len(exported)).To(gomega.Equal(3))
+	sink.mutex.Lock()
+	g.Expect(sink.batch).To(gomega.Equal(2))
+	sink.mutex.Unlock()
+	for i, r := range exported {
+		g.Expect(r.ID > last).To(gomega.BeTrue())
+		g.Expect(r.Model.(*TestObject).ID).To(gomega.Equal(i))
+	}
+	g.Expect(exporter.Last()).To(gomega.Equal(exported[2].ID))
+	// Checkpoint (missing) before the trimmed outbox.
+	err = DB.Export(
+		&Exporter{
+			Sink:       &TestSink{},
+			Checkpoint: filepath.Join(dir, "other"),
+		})
+	g.Expect(errors.Is(err, ExportGapErr)).To(gomega.BeTrue())
+	// Retained for the ended exporter.
+	DB.EndExport(exporter)
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	other := &Exporter{
+		Sink:       &TestSink{},
+		Checkpoint: filepath.Join(dir, "other"),
+	}
+	err = ioutil.WriteFile(
+		other.Checkpoint,
+		[]byte(fmt.Sprintf("%d\n", exporter.Last())),
+		0644)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Export(other)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(drained(DB)).To(gomega.BeFalse())
+	retained, err := DB.(*Client).outbox(0, 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(retained)).To(gomega.Equal(1))
+	DB.EndExport(other)
+	// Encrypted fields.
+	keys := filepath.Join(dir, "keys")
+	secrets := NewWith(
+		"/tmp/test-export-secrets.db",
+		Settings{Export: true, Keys: &KeyFile{Path: keys}},
+		&SecretObject{})
+	err = secrets.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = secrets.Close(true)
+	}()
+	err = secrets.Insert(
+		&SecretObject{
+			ID:       1,
+			Name:     "elmer",
+			Password: "wabbit",
+			Object:   TestEncoded{Name: "json"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	session := secrets.(*Client).pool.Reader()
+	payload := ""
+	row := session.db.QueryRow("SELECT Model FROM Outbox")
+	err = row.Scan(&payload)
+	session.Return()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(payload).To(gomega.ContainSubstring("elmer"))
+	g.Expect(payload).ToNot(gomega.ContainSubstring("wabbit"))
+	g.Expect(payload).ToNot(gomega.ContainSubstring("json"))
+	retained, err = secrets.(*Client).outbox(0, 100)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(retained)).To(gomega.Equal(1))
+	secret := retained[0].Model.(*SecretObject)
+	g.Expect(secret.Password).To(gomega.Equal("wabbit"))
+	g.Expect(secret.Object.Name).To(gomega.Equal("json"))
+}
+
+func TestWatchPredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-watch-predicate.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	object := func(id int) *TestObject {
+		parity := "even"
+		if id%2 != 0 {
+			parity = "odd"
+		}
+		return &TestObject{
+			ID:     id,
+			Name:   fmt.Sprintf("Elmer%d", id),
+			Age:    id * 10,
+			labels: Labels{"parity": parity},
+		}
+	}
+	for i := 0; i < 4; i++ {
+		err = DB.Insert(object(i))
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handler := &TestHandler{
+		options: WatchOptions{
+			Snapshot: true,
+			Predicate: And(
+				Gt("Age", 5),
+				Match(Labels{"parity": "even"})),
+		},
+	}
+	w, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w).ToNot(gomega.BeNil())
+	// matched.
+	err = DB.Insert(object(4))
+	g.Expect(err).To(gomega.BeNil())
+	// not matched.
+	err = DB.Insert(object(5))
+	g.Expect(err).To(gomega.BeNil())
+	updated := object(4)
+	updated.Age = 0
+	// matched (model only) => deleted.
+	err = DB.Update(updated)
+	g.Expect(err).To(gomega.BeNil())
+	updated = object(1)
+	updated.Age = 100
+	// not matched.
+	err = DB.Update(updated)
+	g.Expect(err).To(gomega.BeNil())
+	updated = object(4)
+	updated.Age = 50
+	// matched (updated only) => created.
+	err = DB.Update(updated)
+	g.Expect(err).To(gomega.BeNil())
+	updated = object(4)
+	updated.Age = 60
+	// matched (both) => updated.
+	err = DB.Update(updated)
+	g.Expect(err).To(gomega.BeNil())
+	updated = object(2)
+	updated.labels = Labels{"parity": "odd"}
+	// matched (model labels only) => deleted.
+	err = DB.Update(updated)
+	g.Expect(err).To(gomega.BeNil())
+	// matched (updated labels only) => created.
+	err = DB.Update(object(2))
+	g.Expect(err).To(gomega.BeNil())
+	// not matched.
+	err = DB.Delete(object(0))
+	g.Expect(err).To(gomega.BeNil())
+	// matched.
+	err = DB.Delete(object(2))
+	g.Expect(err).To(gomega.BeNil())
+	handler.wait(func() bool { return len(handler.deleted) > 2 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{2, 4, 4, 2}))
+		g.Expect(handler.updated).To(gomega.Equal([]int{4}))
+		g.Expect(handler.deleted).To(gomega.Equal([]int{4, 2, 2}))
+		g.Expect(len(handler.err)).To(gomega.Equal(0))
+		// Deleted (model only) reports the model matched.
+		for _, event := range handler.all {
+			if event.action == Deleted {
+				g.Expect(event.model.Age).To(gomega.Equal(40))
+				break
+			}
+		}
+	})
+	// Predicates.
+	md, err := Inspect(object(4))
+	g.Expect(err).To(gomega.BeNil())
+	predicates := []Predicate{
+		Eq("ID", 4),
+		Eq("ID", []int{1, 4}),
+		Eq("Name", "Elmer4"),
+		Neq("ID", 3),
+		Gt("Age", 39),
+		Lt("Age", 41),
+		Lt("ID", Field{Name: "Age"}),
+		Or(Eq("ID", 3), Eq("Bool", false)),
+		Match(Labels{"parity": "even"}),
+		Eq("ID", uint(4)),
+		Gt("Age", 39.5),
+		Lt("Age", float32(40.5)),
+		Eq("Name", "Elmer4"),
+		Eq("Slice", "[]"),
+		Eq("Slice", Field{Name: "Slice"}),
+	}
+	for _, p := range predicates {
+		options := FilterOptions{Predicate: p}
+		err = options.Build(md)
+		g.Expect(err).To(gomega.BeNil())
+		matched, err := p.(Matcher).Match(md, object(4).labels)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(matched).To(gomega.BeTrue())
+	}
+	predicates = []Predicate{
+		Eq("ID", 3),
+		Eq("ID", []int{1, 3}),
+		Neq("Name", "Elmer4"),
+		Gt("Age", 40),
+		Lt("Age", 40),
+		And(Eq("ID", 4), Eq("Bool", true)),
+		Match(Labels{"parity": "odd"}),
+		Eq("ID", uint64(3)),
+		Gt("Age", 40.5),
+		Neq("Slice", "[]"),
+	}
+	for _, p := range predicates {
+		options := FilterOptions{Predicate: p}
+		err = options.Build(md)
+		g.Expect(err).To(gomega.BeNil())
+		matched, err := p.(Matcher).Match(md, object(4).labels)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(matched).To(gomega.BeFalse())
+	}
+	// Same as SQL.
+	list := []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Detail: MaxDetail,
+			Predicate: And(
+				Eq("ID", uint(1)),
+				Gt("Age", 99.5),
+				Eq("Slice", "[]")),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	// Invalid.
+	_, err = DB.Watch(
+		&TestObject{},
+		&TestHandler{
+			options: WatchOptions{
+				Predicate: Eq("Unknown", 0),
+			},
+		})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+	_, err = DB.Watch(
+		&TestObject{},
+		&TestHandler{
+			options: WatchOptions{
+				Predicate: &SQLPredicate{},
+			},
+		})
+	g.Expect(errors.Is(err, MatcherErr)).To(gomega.BeTrue())
+}
+
+//
+// Predicate without Matcher.
+type SQLPredicate struct{}
+
+func (p *SQLPredicate) Build(*FilterOptions) error {
+	return nil
+}
+
+func (p *SQLPredicate) Expr() string {
+	return "1"
+}
+
+type BlockingHandler struct {
+	TestHandler
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (w *BlockingHandler) Created(e Event) {
+	if w.entered != nil {
+		close(w.entered)
+		w.entered = nil
+		<-w.gate
+	}
+	w.TestHandler.Created(e)
+}
+
+func TestBackpressure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	queueSize := WatchQueueSize
+	WatchQueueSize = 1
+	defer func() {
+		WatchQueueSize = queueSize
+	}()
+	DB := New("/tmp/test-backpressure.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	// Watch with the handler blocked on the 1st event
+	// and the queue full.
+	watch := func(options WatchOptions) (*Watch, *BlockingHandler) {
+		handler := &BlockingHandler{
+			TestHandler: TestHandler{options: options},
+			entered:     make(chan struct{}),
+			gate:        make(chan struct{}),
+		}
+		entered := handler.entered
+		w, err := DB.Watch(&TestObject{}, handler)
+		g.Expect(err).To(gomega.BeNil())
+		err = DB.Insert(&TestObject{ID: 1})
+		g.Expect(err).To(gomega.BeNil())
+		<-entered
+		err = DB.Insert(&TestObject{ID: 2})
+		g.Expect(err).To(gomega.BeNil())
+		return w, handler
+	}
+	reset := func() {
+		err = DB.With(func(tx *Tx) (err error) {
+			for i := 1; i < 6; i++ {
+				err = tx.Delete(&TestObject{ID: i})
+				if err != nil && !errors.Is(err, NotFound) {
+					return
+				}
+				err = nil
+			}
+			return
+		})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Drop.
+	w, handler := watch(WatchOptions{})
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w.Stats().Dropped).To(gomega.Equal(uint64(1)))
+	handler.read(func() {
+		g.Expect(len(handler.err)).To(gomega.Equal(1))
+	})
+	close(handler.gate)
+	handler.wait(func() bool { return len(handler.created) == 2 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{1, 2}))
+	})
+	DB.EndWatch(w)
+	reset()
+	// Spill.
+	w, handler = watch(WatchOptions{Policy: SpillPolicy})
+	for i := 3; i < 6; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	stats := w.Stats()
+	g.Expect(stats.Queued).To(gomega.Equal(1))
+	g.Expect(stats.Overflow).To(gomega.Equal(3))
+	close(handler.gate)
+	handler.wait(func() bool { return len(handler.created) == 5 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{1, 2, 3, 4, 5}))
+		g.Expect(len(handler.err)).To(gomega.Equal(0))
+	})
+	g.Expect(w.Stats().Delivered).To(gomega.Equal(uint64(5)))
+	DB.EndWatch(w)
+	reset()
+	// Coalesce.
+	w, handler = watch(WatchOptions{Policy: CoalescePolicy})
+	err = DB.Insert(&TestObject{ID: 3, Name: "A"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 3, Name: "B"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 3, Name: "C"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 4})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 4})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 5})
+	g.Expect(err).To(gomega.BeNil())
+	stats = w.Stats()
+	g.Expect(stats.Overflow).To(gomega.Equal(2))
+	g.Expect(stats.Coalesced).To(gomega.Equal(uint64(3)))
+	close(handler.gate)
+	handler.wait(func() bool { return len(handler.created) == 4 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{1, 2, 3, 5}))
+		g.Expect(handler.all[2].model.Name).To(gomega.Equal("C"))
+		g.Expect(len(handler.updated)).To(gomega.Equal(0))
+		g.Expect(len(handler.deleted)).To(gomega.Equal(0))
+	})
+	DB.EndWatch(w)
+	reset()
+	// Terminate.
+	w, handler = watch(WatchOptions{Policy: TerminatePolicy})
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	handler.read(func() {
+		g.Expect(len(handler.err)).To(gomega.Equal(1))
+		g.Expect(errors.Is(handler.err[0], BackpressureErr)).To(gomega.BeTrue())
+	})
+	close(handler.gate)
+	g.Expect(handler.wait(func() bool { return handler.done })).To(gomega.BeTrue())
+	g.Expect(w.Alive()).To(gomega.BeFalse())
+	reset()
+	// Block.
+	w, handler = watch(
+		WatchOptions{
+			Policy:  BlockPolicy,
+			Timeout: time.Millisecond * 50,
+		})
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w.Stats().Blocked >= time.Millisecond*50).To(gomega.BeTrue())
+	handler.read(func() {
+		g.Expect(errors.Is(handler.err[0], BackpressureErr)).To(gomega.BeTrue())
+	})
+	close(handler.gate)
+	g.Expect(handler.wait(func() bool { return handler.done })).To(gomega.BeTrue())
+	reset()
+	// Block (journal not locked while blocked).
+	w, handler = watch(
+		WatchOptions{
+			Policy:  BlockPolicy,
+			Timeout: time.Second * 10,
+		})
+	committed := make(chan error, 1)
+	go func() {
+		committed <- DB.Insert(&TestObject{ID: 3})
+	}()
+	time.Sleep(time.Millisecond * 20)
+	status := make(chan []WatchStatus, 1)
+	go func() {
+		status <- DB.WatchStatus()
+	}()
+	select {
+	case <-status:
+	case <-time.After(time.Second * 5):
+		t.Fatal("journal locked by the blocked committer.")
+	}
+	close(handler.gate)
+	g.Expect(<-committed).To(gomega.BeNil())
+	DB.EndWatch(w)
+}
+
+func TestCoalesce(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-coalesce.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	for _, id := range []int{3, 4} {
+		err = DB.Insert(&TestObject{ID: id, Name: "A"})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Flushed when ended.
+	handler := &TestHandler{
+		options: WatchOptions{
+			Coalesce: time.Hour,
+		},
+	}
+	w, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1, Name: "A"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 1, Name: "B"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 2})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 3, Name: "B"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 1, Name: "C"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 2})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 3, Name: "C"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 4})
+	g.Expect(err).To(gomega.BeNil())
+	handler.read(func() {
+		g.Expect(len(handler.all)).To(gomega.Equal(0))
+	})
+	DB.EndWatch(w)
+	handler.wait(func() bool { return handler.done })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{1}))
+		g.Expect(handler.updated).To(gomega.Equal([]int{3}))
+		g.Expect(handler.deleted).To(gomega.Equal([]int{4}))
+		g.Expect(handler.all[0].model.Name).To(gomega.Equal("C"))
+		g.Expect(handler.all[1].model.Name).To(gomega.Equal("A"))
+		g.Expect(handler.all[1].updated.Name).To(gomega.Equal("C"))
+	})
+	g.Expect(w.Stats().Coalesced).To(gomega.Equal(uint64(4)))
+	// Flushed per window.
+	handler = &TestHandler{
+		options: WatchOptions{
+			Coalesce: time.Millisecond * 20,
+		},
+	}
+	w, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 5})
+	g.Expect(err).To(gomega.BeNil())
+	handler.wait(func() bool { return len(handler.created) > 0 })
+	handler.read(func() {
+		g.Expect(handler.created).To(gomega.Equal([]int{5}))
+		g.Expect(handler.done).To(gomega.BeFalse())
+	})
+}
+
+func TestWatchLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-watch-labels.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	handlerA := &TestHandler{
+		options: WatchOptions{
+			IncludeLabels: []string{"collector", "api"},
+		},
+	}
+	_, err = DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	handlerB := &TestHandler{
+		options: WatchOptions{
+			ExcludeLabels: []string{"api"},
+		},
+	}
+	_, err = DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	insert := func(id int, labels ...string) {
+		err = DB.With(
+			func(tx *Tx) error {
+				return tx.Insert(&TestObject{ID: id})
+			},
+			labels...)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	insert(0)
+	insert(1, "collector")
+	insert(2, "api", "other")
+	insert(3, "other")
+	handlerA.wait(func() bool { return len(handlerA.created) == 2 })
+	handlerB.wait(func() bool { return len(handlerB.created) == 3 })
+	handlerA.read(func() {
+		g.Expect(handlerA.created).To(gomega.Equal([]int{1, 2}))
+	})
+	handlerB.read(func() {
+		g.Expect(handlerB.created).To(gomega.Equal([]int{0, 1, 3}))
+	})
+}
+
+func TestDiff(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	objA := &TestObject{
+		ID:     1,
+		Name:   "A",
+		Object: TestEncoded{Name: "A"},
+		Slice:  []string{"a", "b", "c"},
+		Map:    map[string]int{"x": 1, "y": 2},
+	}
+	objB := &TestObject{
+		ID:     1,
+		Name:   "B",
+		Object: TestEncoded{Name: "B/~"},
+		Slice:  []string{"a", "z"},
+		Map:    map[string]int{"x": 1, "z": 3},
+	}
+	changes, err := Diff(objA, objB)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(changes)).To(gomega.Equal(4))
+	g.Expect(changes[0].Field).To(gomega.Equal("Name"))
+	g.Expect(changes[0].Old).To(gomega.Equal("A"))
+	g.Expect(changes[0].New).To(gomega.Equal("B"))
+	g.Expect(changes[0].Patch).To(gomega.BeNil())
+	patch := func(change Change) string {
+		b, err := json.Marshal(change.Patch)
+		g.Expect(err).To(gomega.BeNil())
+		return string(b)
+	}
+	g.Expect(changes[1].Field).To(gomega.Equal("Object"))
+	g.Expect(patch(changes[1])).To(
+		gomega.Equal(`[{"op":"replace","path":"/Name","value":"B/~"}]`))
+	g.Expect(changes[2].Field).To(gomega.Equal("Slice"))
+	g.Expect(patch(changes[2])).To(
+		gomega.Equal(`[{"op":"replace","path":"/1","value":"z"},{"op":"remove","path":"/2"}]`))
+	g.Expect(changes[3].Field).To(gomega.Equal("Map"))
+	g.Expect(patch(changes[3])).To(
+		gomega.Equal(`[{"op":"remove","path":"/y"},{"op":"add","path":"/z","value":3}]`))
+	changes, err = Diff(objA, objA)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(changes)).To(gomega.Equal(0))
+	g.Expect(escape("a/b~c")).To(gomega.Equal("a~1b~0c"))
+	// JSON (resources).
+	type Resource struct {
+		Name   string         `json:"name"`
+		Labels []string       `json:"labels,omitempty"`
+		Object map[string]int `json:"object"`
+	}
+	changes, err = DiffJSON(
+		&Resource{Name: "A", Object: map[string]int{"x": 1}},
+		&Resource{Name: "B", Labels: []string{"a"}, Object: map[string]int{"x": 2}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(changes)).To(gomega.Equal(3))
+	g.Expect(changes[0].Field).To(gomega.Equal("labels"))
+	g.Expect(changes[0].Old).To(gomega.BeNil())
+	g.Expect(changes[0].New).To(gomega.Equal([]interface{}{"a"}))
+	g.Expect(changes[0].Patch).To(gomega.BeNil())
+	g.Expect(changes[1].Field).To(gomega.Equal("name"))
+	g.Expect(changes[1].Old).To(gomega.Equal("A"))
+	g.Expect(changes[1].New).To(gomega.Equal("B"))
+	g.Expect(changes[2].Field).To(gomega.Equal("object"))
+	g.Expect(patch(changes[2])).To(
+		gomega.Equal(`[{"op":"replace","path":"/x","value":2}]`))
+	_, err = DiffJSON(1, 2)
+	g.Expect(err).ToNot(gomega.BeNil())
+	// Watch.
+	DB := New("/tmp/test-diff.db", &TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	err = DB.Insert(objA)
+	g.Expect(err).To(gomega.BeNil())
+	handler := &DiffHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	objB.Rev = objA.Rev
+	err = DB.Update(objB)
+	g.Expect(err).To(gomega.BeNil())
+	handler.wait(func() bool { return handler.changes != nil })
+	fields := []string{}
+	handler.read(func() {
+		for _, change := range handler.changes {
+			fields = append(fields, change.Field)
+		}
+	})
+	g.Expect(fields).To(gomega.Equal([]string{"Rev", "Name", "Object", "Slice", "Map"}))
+}
+
+type DiffHandler struct {
+	StockEventHandler
+	Synced
+	changes []Change
+}
+
+func (w *DiffHandler) Options() WatchOptions {
+	return WatchOptions{Diff: true}
+}
+
+func (w *DiffHandler) Updated(e Event) {
+	w.update(func() {
+		w.changes = e.Changes
+	})
+}
+
+func TestTriggers(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test-triggers.db",
+		&TestObject{},
+		&PlainObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	err = DB.Insert(&PlainObject{ID: 1, Name: "count"})
+	g.Expect(err).To(gomega.BeNil())
+	// Rollup (count).
+	DB.Trigger(
+		&TestObject{},
+		Created|Deleted,
+		func(tx *Tx, event Event) (err error) {
+			count := &PlainObject{ID: 1}
+			err = tx.Get(count)
+			if err != nil {
+				return
+			}
+			if event.Action == Created {
+				count.Age++
+			} else {
+				count.Age--
+			}
+			err = tx.Update(count)
+			return
+		})
+	// Validation.
+	DB.Trigger(
+		&TestObject{},
+		Updated,
+		func(tx *Tx, event Event) (err error) {
+			if event.Updated.(*TestObject).Name == "bad" {
+				err = errors.New("bad name")
+			}
+			return
+		})
+	count := func() int {
+		count := &PlainObject{ID: 1}
+		err := DB.Get(count)
+		g.Expect(err).To(gomega.BeNil())
+		return count.Age
+	}
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	g.Expect(count()).To(gomega.Equal(3))
+	err = DB.Delete(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count()).To(gomega.Equal(2))
+	// Rolled back (With).
+	err = DB.With(func(tx *Tx) (err error) {
+		err = tx.Insert(&TestObject{ID: 10})
+		if err != nil {
+			return
+		}
+		err = tx.Update(&TestObject{ID: 1, Name: "bad"})
+		return
+	})
+	g.Expect(err).ToNot(gomega.BeNil())
+	err = DB.Get(&TestObject{ID: 10})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	g.Expect(count()).To(gomega.Equal(2))
+	// Rolled back (Commit).
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Insert(&TestObject{ID: 11})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Update(&TestObject{ID: 1, Name: "bad"})
+	g.Expect(err).ToNot(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).ToNot(gomega.BeNil())
+	err = DB.Get(&TestObject{ID: 11})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	g.Expect(count()).To(gomega.Equal(2))
+	// Depth.
+	DB.Trigger(
+		&PlainObject{},
+		Updated,
+		func(tx *Tx, event Event) (err error) {
+			err = tx.Update(event.Updated)
+			return
+		})
+	err = DB.Insert(&TestObject{ID: 12})
+	g.Expect(errors.Is(err, TriggerDepthErr)).To(gomega.BeTrue())
+	g.Expect(count()).To(gomega.Equal(2))
+}
+
+type PanicHandler struct {
+	TestHandler
+}
+
+func (w *PanicHandler) Created(e Event) {
+	if e.Model.(*TestObject).ID == 1 {
+		panic("bad handler")
+	}
+	w.TestHandler.Created(e)
+}
+
+func TestHandlerPanic(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-handler-panic.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	handlerA := &PanicHandler{
+		TestHandler: TestHandler{
+			options: WatchOptions{
+				OnPanic: ContinueOnPanic,
+			},
+		},
+	}
+	wA, err := DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	handlerB := &PanicHandler{}
+	wB, err := DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handlerA.wait(func() bool { return len(handlerA.created) == 2 })
+	handlerB.wait(func() bool { return handlerB.done })
+	// Continued.
+	handlerA.read(func() {
+		g.Expect(handlerA.created).To(gomega.Equal([]int{0, 2}))
+		g.Expect(len(handlerA.err)).To(gomega.Equal(1))
+		g.Expect(errors.Is(handlerA.err[0], HandlerPanicErr)).To(gomega.BeTrue())
+	})
+	g.Expect(wA.Alive()).To(gomega.BeTrue())
+	// Terminated.
+	handlerB.read(func() {
+		g.Expect(handlerB.created).To(gomega.Equal([]int{0}))
+		g.Expect(len(handlerB.err)).To(gomega.Equal(1))
+		g.Expect(errors.Is(handlerB.err[0], HandlerPanicErr)).To(gomega.BeTrue())
+		g.Expect(handlerB.done).To(gomega.BeTrue())
+	})
+	g.Expect(wB.Alive()).To(gomega.BeFalse())
+	// Status.
+	status := DB.WatchStatus()
+	g.Expect(len(status)).To(gomega.Equal(1))
+	g.Expect(status[0].ID).To(gomega.Equal(wA.id))
+	g.Expect(status[0].Kinds).To(gomega.Equal([]string{"TestObject"}))
+	g.Expect(status[0].Delivered).To(gomega.Equal(uint64(3)))
+	g.Expect(status[0].Panics).To(gomega.Equal(uint64(1)))
+	g.Expect(errors.Is(status[0].LastError, HandlerPanicErr)).To(gomega.BeTrue())
+}
+
+type BoundaryHandler struct {
+	StockEventHandler
+	Synced
+	parity   bool
+	boundary uint64
+	snapshot []int
+	created  []int
+	events   []uint64
+}
+
+func (w *BoundaryHandler) Options() WatchOptions {
+	return WatchOptions{Snapshot: true}
+}
+
+func (w *BoundaryHandler) ParityAt(id uint64) {
+	w.update(func() {
+		w.boundary = id
+		w.parity = true
+	})
+}
+
+func (w *BoundaryHandler) Created(e Event) {
+	id := e.Model.(*TestObject).ID
+	w.update(func() {
+		if !w.parity {
+			w.snapshot = append(w.snapshot, id)
+			return
+		}
+		w.created = append(w.created, id)
+		w.events = append(w.events, e.ID)
+	})
+}
+
+func TestSnapshotBoundary(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-boundary.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	n := 300
+	inserted := make(chan int, n)
+	go func() {
+		for i := 0; i < n; i++ {
+			_ = DB.Insert(&TestObject{ID: i})
+			inserted <- i
+		}
+	}()
+	handlers := []*BoundaryHandler{}
+	watches := []*Watch{}
+	for i := 0; i < n; i++ {
+		<-inserted
+		if i%100 == 50 {
+			handler := &BoundaryHandler{}
+			w, err := DB.Watch(&TestObject{}, handler)
+			g.Expect(err).To(gomega.BeNil())
+			handlers = append(handlers, handler)
+			watches = append(watches, w)
+		}
+	}
+	for i, handler := range handlers {
+		handler.wait(func() bool {
+			return len(handler.snapshot)+len(handler.created) >= n
+		})
+		handler.read(func() {
+			g.Expect(handler.parity).To(gomega.BeTrue())
+			g.Expect(handler.boundary).To(gomega.Equal(watches[i].Boundary()))
+			all := append([]int{}, handler.snapshot...)
+			all = append(all, handler.created...)
+			sort.Ints(all)
+			g.Expect(len(all)).To(gomega.Equal(n))
+			for id := 0; id < n; id++ {
+				g.Expect(all[id]).To(gomega.Equal(id))
+			}
+			for _, id := range handler.events {
+				g.Expect(id > handler.boundary).To(gomega.BeTrue())
+			}
+		})
+	}
+	// Boundary is the last event reported by this DB.
+	DB2 := New("/tmp/test-boundary2.db", &TestObject{})
+	err = DB2.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB2.Close(true)
+	}()
+	w, err := DB.Watch(&TestObject{}, &BoundaryHandler{})
+	g.Expect(err).To(gomega.BeNil())
+	boundary := w.Boundary()
+	err = DB2.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	w, err = DB.Watch(&TestObject{}, &BoundaryHandler{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w.Boundary()).To(gomega.Equal(boundary))
+	w, err = DB2.Watch(&TestObject{}, &BoundaryHandler{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w.Boundary() > boundary).To(gomega.BeTrue())
+}
+
+func TestWatchInTx(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-watch-tx.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	// Not blocked by the transaction.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	done := make(chan error, 1)
+	go func() {
+		w, err := DB.Watch(&TestObject{}, &TestHandler{})
+		if err == nil {
+			DB.EndWatch(w)
+		}
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		g.Expect(err).To(gomega.BeNil())
+	case <-time.After(time.Second * 10):
+		t.Fatal("watch blocked by the transaction.")
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+}
+
+type KindHandler struct {
+	StockEventHandler
+	Synced
+	options WatchOptions
+	parity  int
+	events  []string
+	done    bool
+}
+
+func (w *KindHandler) Options() WatchOptions {
+	return w.options
+}
+
+func (w *KindHandler) Parity() {
+	w.update(func() {
+		w.parity = len(w.events)
+	})
+}
+
+func (w *KindHandler) Created(e Event) {
+	w.update(func() {
+		w.events = append(w.events, fmt.Sprintf("%s", e.Model))
+	})
+}
+
+func (w *KindHandler) End() {
+	w.update(func() {
+		w.done = true
+	})
+}
+
+func TestWatchKinds(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := NewWith(
+		"/tmp/test-watch-kinds.db",
+		Settings{Export: true},
+		&TestObject{},
+		&PlainObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	err = DB.Insert(&TestObject{ID: 1, Name: "T1"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&PlainObject{ID: 1, Name: "P1"})
+	g.Expect(err).To(gomega.BeNil())
+	handlerA := &KindHandler{
+		options: WatchOptions{
+			Snapshot: true,
+			Kinds: []Model{
+				&TestObject{},
+				&PlainObject{},
+			},
+		},
+	}
+	w, err := DB.Watch(nil, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w.Kinds()).To(gomega.Equal([]string{"PlainObject", "TestObject"}))
+	handlerB := &KindHandler{
+		options: WatchOptions{
+			Snapshot: true,
+			AllKinds: true,
+		},
+	}
+	_, err = DB.Watch(nil, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.With(func(tx *Tx) (err error) {
+		err = tx.Insert(&PlainObject{ID: 2, Name: "P2"})
+		if err != nil {
+			return
+		}
+		err = tx.Insert(&TestObject{ID: 2, Name: "T2"})
+		return
+	})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 3, Name: "T3"})
+	g.Expect(err).To(gomega.BeNil())
+	for _, handler := range []*KindHandler{handlerA, handlerB} {
+		handler.wait(func() bool { return len(handler.events) == 5 })
+		handler.read(func() {
+			g.Expect(handler.parity).To(gomega.Equal(2))
+			g.Expect(handler.events[:2]).To(
+				gomega.ConsistOf(
+					"TestObject: id: 1, name:T1",
+					"PlainObject: id: 1, name:P1"))
+			g.Expect(handler.events[2:]).To(
+				gomega.Equal([]string{
+					"PlainObject: id: 2, name:P2",
+					"TestObject: id: 2, name:T2",
+					"TestObject: id: 3, name:T3",
+				}))
+		})
+	}
+	// Invalid.
+	_, err = DB.Watch(nil, &KindHandler{})
+	g.Expect(errors.Is(err, WatchKindErr)).To(gomega.BeTrue())
+	_, err = DB.Watch(
+		nil,
+		&KindHandler{
+			options: WatchOptions{
+				AllKinds:  true,
+				Predicate: Eq("D1", "x"),
+			},
+		})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+}
+
+type ResumeHandler struct {
+	StockEventHandler
+	Synced
+	options WatchOptions
+	parity  bool
+	events  []uint64
+	created []int
+}
+
+func (w *ResumeHandler) Options() WatchOptions {
+	return w.options
+}
+
+func (w *ResumeHandler) Parity() {
+	w.update(func() {
+		w.parity = true
+	})
+}
+
+func (w *ResumeHandler) Created(e Event) {
+	w.update(func() {
+		if !w.parity {
+			return
+		}
+		w.events = append(w.events, e.ID)
+		w.created = append(w.created, e.Model.(*TestObject).ID)
+	})
+}
+
+func TestResume(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	limit := EventLogLimit
+	segment := EventLogSegment
+	EventLogLimit = 4
+	EventLogSegment = 2
+	defer func() {
+		EventLogLimit = limit
+		EventLogSegment = segment
+	}()
+	DB := New("/tmp/test-resume.db", &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	// Wait for n created and return (copies of) the
+	// event IDs and the created model IDs.
+	wait := func(h *ResumeHandler, n int) (events []uint64, created []int) {
+		h.wait(func() bool { return len(h.created) >= n })
+		h.read(func() {
+			events = append(events, h.events...)
+			created = append(created, h.created...)
+		})
+		return
+	}
+	handlerA := &ResumeHandler{}
+	_, err = DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	eventsA, _ := wait(handlerA, 3)
+	g.Expect(len(eventsA)).To(gomega.Equal(3))
+	// Resumed.
+	handlerB := &ResumeHandler{
+		options: WatchOptions{
+			Snapshot:   true,
+			ResumeFrom: eventsA[0],
+		},
+	}
+	handlerB.parity = true
+	_, err = DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	eventsB, created := wait(handlerB, 3)
+	g.Expect(created).To(gomega.Equal([]int{1, 2, 3}))
+	// Resumed (current).
+	handlerC := &ResumeHandler{
+		options: WatchOptions{
+			ResumeFrom: eventsB[2],
+		},
+	}
+	_, err = DB.Watch(&TestObject{}, handlerC)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 4})
+	g.Expect(err).To(gomega.BeNil())
+	eventsC, created := wait(handlerC, 1)
+	handlerC.read(func() {
+		g.Expect(handlerC.parity).To(gomega.BeTrue())
+	})
+	g.Expect(created).To(gomega.Equal([]int{4}))
+	// Too old.
+	_, err = DB.Watch(
+		&TestObject{},
+		&ResumeHandler{
+			options: WatchOptions{
+				ResumeFrom: eventsA[0],
+			},
+		})
+	g.Expect(errors.Is(err, ResumeTooOldErr)).To(gomega.BeTrue())
+	// Unknown.
+	_, err = DB.Watch(
+		&TestObject{},
+		&ResumeHandler{
+			options: WatchOptions{
+				ResumeFrom: math.MaxUint64,
+			},
+		})
+	g.Expect(errors.Is(err, ResumeTooOldErr)).To(gomega.BeTrue())
+	// Resumed after restart.
+	_ = DB.Close(false)
+	DB = New("/tmp/test-resume.db", &TestObject{})
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 5})
+	g.Expect(err).To(gomega.BeNil())
+	handlerD := &ResumeHandler{
+		options: WatchOptions{
+			ResumeFrom: eventsB[2],
+		},
+	}
+	handlerD.parity = true
+	_, err = DB.Watch(&TestObject{}, handlerD)
+	g.Expect(err).To(gomega.BeNil())
+	eventsD, created := wait(handlerD, 2)
+	g.Expect(created).To(gomega.Equal([]int{4, 5}))
+	g.Expect(eventsD[1] > eventsC[0]).To(gomega.BeTrue())
+	// Too old after restart.
+	_, err = DB.Watch(
+		&TestObject{},
+		&ResumeHandler{
+			options: WatchOptions{
+				ResumeFrom: eventsA[0],
+			},
+		})
+	g.Expect(errors.Is(err, ResumeTooOldErr)).To(gomega.BeTrue())
+	// Restart after crash (events not saved).
+	_ = DB.Close(false)
+	last := eventsD[1]
+	err = ioutil.WriteFile(
+		"/tmp/test-resume.db.events/reserved",
+		[]byte(fmt.Sprintf("%d\n", last+10)),
+		0644)
+	g.Expect(err).To(gomega.BeNil())
+	DB = New("/tmp/test-resume.db", &TestObject{})
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Watch(
+		&TestObject{},
+		&ResumeHandler{
+			options: WatchOptions{
+				ResumeFrom: last,
+			},
+		})
+	g.Expect(errors.Is(err, ResumeTooOldErr)).To(gomega.BeTrue())
+	handlerE := &ResumeHandler{
+		options: WatchOptions{
+			ResumeFrom: last + 10,
+		},
+	}
+	handlerE.parity = true
+	_, err = DB.Watch(&TestObject{}, handlerE)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 6})
+	g.Expect(err).To(gomega.BeNil())
+	eventsE, created := wait(handlerE, 1)
+	g.Expect(created).To(gomega.Equal([]int{6}))
+	g.Expect(eventsE[0] > last+10).To(gomega.BeTrue())
+	// Encrypted fields.
+	keys := "/tmp/test-resume.keys"
+	_ = os.Remove(keys)
+	defer func() {
+		_ = os.Remove(keys)
+	}()
+	secrets := NewWith(
+		"/tmp/test-resume-secrets.db",
+		Settings{Keys: &KeyFile{Path: keys}},
+		&SecretObject{})
+	err = secrets.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = secrets.Close(true)
+	}()
+	for i := 0; i < 3; i++ {
+		err = secrets.Insert(
+			&SecretObject{
+				ID:       i,
+				Name:     "elmer",
+				Password: "wabbit",
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	_ = secrets.Close(false)
+	matched, _ := filepath.Glob("/tmp/test-resume-secrets.db.events/*")
+	g.Expect(len(matched) > 0).To(gomega.BeTrue())
+	for _, p := range matched {
+		b, err := ioutil.ReadFile(p)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(string(b)).ToNot(gomega.ContainSubstring("wabbit"))
+	}
+	secrets = NewWith(
+		"/tmp/test-resume-secrets.db",
+		Settings{Keys: &KeyFile{Path: keys}},
+		&SecretObject{})
+	err = secrets.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	journal := &secrets.(*Client).journal
+	journal.mutex.Lock()
+	itr, err := journal.events.since(journal.events.discarded)
+	journal.mutex.Unlock()
+	g.Expect(err).To(gomega.BeNil())
+	replayed := 0
+	for {
+		event := Event{}
+		if !event.next(itr) {
+			break
+		}
+		g.Expect(event.Model.(*SecretObject).Password).To(gomega.Equal("wabbit"))
+		replayed++
+	}
+	itr.Close()
+	g.Expect(replayed > 0).To(gomega.BeTrue())
+}
+
+func TestCloseDB(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New("/tmp/test-close-db.db", &TestObject{})
+	err := DB.Open(true)
+	defer func() {
+		_ = DB.Close(false)
+	}()
+	g.Expect(err).To(gomega.BeNil())
+	handler := &TestHandler{
+		options: WatchOptions{Snapshot: true},
+		name:    "A",
+	}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	handler.wait(func() bool { return handler.parity })
+	handler.read(func() {
+		g.Expect(handler.started).To(gomega.BeTrue())
+		g.Expect(handler.done).To(gomega.BeFalse())
+	})
+	_ = DB.Close(true)
+	for _, session := range DB.(*Client).pool.sessions {
+		g.Expect(session.closed).To(gomega.BeTrue())
+	}
+	g.Expect(handler.wait(func() bool { return handler.done })).To(gomega.BeTrue())
+	g.Expect(watch.Alive()).To(gomega.BeFalse())
+}
+
 func TestMutatingWatch(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New("/tmp/test-mutating-watch.db", &TestObject{})
@@ -1226,12 +3110,10 @@ func TestMutatingWatch(t *testing.T) {
 		g.Expect(err).To(gomega.BeNil())
 	}
 
-	for {
-		time.Sleep(time.Millisecond * 10)
-		if len(handlerA.updated) == N*2 {
-			break
-		}
-	}
+	met := handlerA.wait(func() bool {
+		return len(handlerA.updated) == N*2
+	})
+	g.Expect(met).To(gomega.BeTrue())
 }
 
 func TestExecute(t *testing.T) {
@@ -1473,6 +3355,320 @@ func __TestConcurrency(t *testing.T) {
 	fmt.Println(time.Since(mark))
 }
 
+func TestTTL(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := NewWith(
+		"/tmp/test-ttl.db",
+		Settings{
+			ReapInterval: time.Millisecond * 10,
+			ReapBatch:    3,
+		},
+		&ExpiringObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	deleted := make(chan int, 100)
+	w, err := DB.Watch(&ExpiringObject{}, &ttlHandler{deleted: deleted})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(w).ToNot(gomega.BeNil())
+	now := time.Now().Unix()
+	for i := 0; i < 10; i++ {
+		object := &ExpiringObject{ID: i}
+		switch i % 3 {
+		case 0:
+			object.Expires = now - 1
+		case 1:
+			object.Expires = now + 3600
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	reaped := []int{}
+	for len(reaped) < 4 {
+		select {
+		case id := <-deleted:
+			reaped = append(reaped, id)
+		case <-time.After(time.Second * 5):
+			t.Fatal("reap timed out.")
+		}
+	}
+	g.Expect(reaped).To(gomega.ConsistOf(0, 3, 6, 9))
+	n, err := DB.Count(&ExpiringObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(6)))
+	// Defaults.
+	reaper := &Reaper{
+		client: &Client{
+			settings: Settings{
+				ReapInterval: -1,
+				ReapBatch:    -1,
+			},
+		},
+	}
+	g.Expect(reaper.interval()).To(gomega.Equal(DefaultReapInterval))
+	g.Expect(reaper.batch()).To(gomega.Equal(DefaultReapBatch))
+	// Invalid.
+	type Invalid struct {
+		ID      int    `sql:"pk"`
+		Expires string `sql:"ttl"`
+	}
+	_, err = Inspect(&Invalid{})
+	g.Expect(errors.Is(err, TTLErr)).To(gomega.BeTrue())
+}
+
+type ttlHandler struct {
+	StockEventHandler
+	deleted chan int
+}
+
+func (h *ttlHandler) Deleted(e Event) {
+	h.deleted <- e.Model.(*ExpiringObject).ID
+}
+
+func TestEncrypted(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	raw := func(DB DB) (password, object string) {
+		session := DB.(*Client).pool.Reader()
+		defer session.Return()
+		row := session.db.QueryRow("SELECT password, object FROM SecretObject")
+		_ = row.Scan(&password, &object)
+		return
+	}
+
+	path := "/tmp/test-encrypted.keys"
+	_ = os.Remove(path)
+	keyFile := &KeyFile{Path: path}
+	DB := NewWith(
+		"/tmp/test-encrypted.db",
+		Settings{Keys: keyFile},
+		&SecretObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	secret := &SecretObject{
+		ID:       1,
+		Name:     "elmer",
+		Password: "wabbit",
+		Object:   TestEncoded{Name: "json"},
+	}
+	err = DB.Insert(secret)
+	g.Expect(err).To(gomega.BeNil())
+	// Stored encrypted.
+	password, object := raw(DB)
+	g.Expect(password).To(gomega.HavePrefix(EncPrefix + "k1:"))
+	g.Expect(password).ToNot(gomega.ContainSubstring("wabbit"))
+	g.Expect(object).To(gomega.HavePrefix(EncPrefix + "k1:"))
+	// Get (decrypted).
+	got := &SecretObject{ID: 1}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Password).To(gomega.Equal("wabbit"))
+	g.Expect(got.Object.Name).To(gomega.Equal("json"))
+	// Predicate not supported.
+	list := []SecretObject{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Password", "wabbit")})
+	g.Expect(errors.Is(err, PredicateFieldErr)).To(gomega.BeTrue())
+	// Rotate and re-encrypt.
+	err = keyFile.Rotate()
+	g.Expect(err).To(gomega.BeNil())
+	n, err := DB.Reencrypt()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	password, object = raw(DB)
+	g.Expect(password).To(gomega.HavePrefix(EncPrefix + "k2:"))
+	g.Expect(object).To(gomega.HavePrefix(EncPrefix + "k2:"))
+	n, err = DB.Reencrypt()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	// Values are bound to the row.
+	err = DB.Insert(
+		&SecretObject{
+			ID:       2,
+			Name:     "bugs",
+			Password: "carrot",
+		})
+	g.Expect(err).To(gomega.BeNil())
+	exec := func(stmt string) {
+		session := DB.(*Client).pool.Writer()
+		defer session.Return()
+		_, err := session.db.Exec(stmt)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	exec(
+		"UPDATE SecretObject SET password = " +
+			"(SELECT password FROM SecretObject WHERE id = 1) " +
+			"WHERE id = 2")
+	got = &SecretObject{ID: 2}
+	err = DB.Get(got)
+	g.Expect(errors.Is(err, CipherTextErr)).To(gomega.BeTrue())
+	// Legacy (TEXT) value with the prefix is plain.
+	exec("UPDATE SecretObject SET password = 'enc:k1:legacy' WHERE id = 2")
+	got = &SecretObject{ID: 2}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Password).To(gomega.Equal("enc:k1:legacy"))
+	n, err = DB.Reencrypt()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	got = &SecretObject{ID: 2}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Password).To(gomega.Equal("enc:k1:legacy"))
+	// Keys rotated by another process.
+	err = (&KeyFile{Path: path}).Rotate()
+	g.Expect(err).To(gomega.BeNil())
+	current, _, err := keyFile.Current()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(current).To(gomega.Equal("k3"))
+	n, err = DB.Reencrypt()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	password, _ = raw(DB)
+	g.Expect(password).To(gomega.HavePrefix(EncPrefix + "k3:"))
+	// Keys reloaded from the file (another client).
+	DB2 := NewWith(
+		"/tmp/test-encrypted.db",
+		Settings{Keys: &KeyFile{Path: path}},
+		&SecretObject{})
+	err = DB2.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	got = &SecretObject{ID: 1}
+	err = DB2.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Password).To(gomega.Equal("wabbit"))
+	g.Expect(got.Object.Name).To(gomega.Equal("json"))
+	_ = DB2.Close(false)
+	// Wrong keys (another client).
+	otherPath := "/tmp/test-encrypted-other.keys"
+	_ = os.Remove(otherPath)
+	defer func() {
+		_ = os.Remove(otherPath)
+	}()
+	DB3 := NewWith(
+		"/tmp/test-encrypted.db",
+		Settings{Keys: &KeyFile{Path: otherPath}},
+		&SecretObject{})
+	err = DB3.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	got = &SecretObject{ID: 1, Password: "unchanged"}
+	err = DB3.Get(got)
+	g.Expect(errors.Is(err, KeyNotFoundErr)).To(gomega.BeTrue())
+	g.Expect(got.Password).To(gomega.Equal("unchanged"))
+	list = []SecretObject{}
+	err = DB3.List(&list, ListOptions{Detail: MaxDetail})
+	g.Expect(errors.Is(err, KeyNotFoundErr)).To(gomega.BeTrue())
+	_ = DB3.Close(false)
+	// Key provider required.
+	DB4 := New("/tmp/test-encrypted-nokeys.db", &SecretObject{})
+	g.Expect(func() { _ = DB4.Open(true) }).To(gomega.Panic())
+	_ = os.Remove("/tmp/test-encrypted-nokeys.db")
+	// Invalid definition.
+	type Invalid struct {
+		ID  int `sql:"pk"`
+		Age int `sql:"encrypt"`
+	}
+	_, err = Inspect(&Invalid{})
+	g.Expect(errors.Is(err, EncryptedErr)).To(gomega.BeTrue())
+}
+
+func TestCompressed(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	raw := func(DB DB) (text, object string) {
+		session := DB.(*Client).pool.Reader()
+		defer session.Return()
+		row := session.db.QueryRow("SELECT text, object FROM CompressedObject")
+		_ = row.Scan(&text, &object)
+		return
+	}
+
+	DB := New("/tmp/test-compressed.db", &CompressedObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = DB.Close(true)
+	}()
+	text := strings.Repeat("hello world ", 1000)
+	object := &CompressedObject{
+		ID:     1,
+		Name:   "elmer",
+		Text:   text,
+		Object: TestEncoded{Name: "json"},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Stored compressed.
+	storedText, storedObject := raw(DB)
+	g.Expect(storedText).To(gomega.HavePrefix("\x1f\x8b"))
+	g.Expect(len(storedText) < len(text)).To(gomega.BeTrue())
+	g.Expect(storedObject).To(gomega.HavePrefix("\x1f\x8b"))
+	// Get (decompressed).
+	got := &CompressedObject{ID: 1}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Text).To(gomega.Equal(text))
+	g.Expect(got.Object.Name).To(gomega.Equal("json"))
+	// Predicate not supported.
+	list := []CompressedObject{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Text", "hello")})
+	g.Expect(errors.Is(err, PredicateFieldErr)).To(gomega.BeTrue())
+	// Migrate (uncompressed) values.
+	session := DB.(*Client).pool.Writer()
+	_, err = session.db.Exec(
+		"UPDATE CompressedObject SET text = 'plain', object = '{\"Name\":\"plain\"}'")
+	session.Return()
+	g.Expect(err).To(gomega.BeNil())
+	got = &CompressedObject{ID: 1}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Text).To(gomega.Equal("plain"))
+	g.Expect(got.Object.Name).To(gomega.Equal("plain"))
+	// Legacy (TEXT) value with the gzip magic prefix is plain.
+	legacy := "\x1f\x8blegacy"
+	session = DB.(*Client).pool.Writer()
+	_, err = session.db.Exec(
+		"UPDATE CompressedObject SET text = CAST(X'1F8B' AS TEXT) || 'legacy'")
+	session.Return()
+	g.Expect(err).To(gomega.BeNil())
+	got = &CompressedObject{ID: 1}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Text).To(gomega.Equal(legacy))
+	n, err := DB.Compress()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	storedText, storedObject = raw(DB)
+	g.Expect(storedText).To(gomega.HavePrefix("\x1f\x8b"))
+	g.Expect(storedObject).To(gomega.HavePrefix("\x1f\x8b"))
+	n, err = DB.Compress()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	// Re-encrypt without keys (nothing encrypted).
+	n, err = DB.Reencrypt()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	got = &CompressedObject{ID: 1}
+	err = DB.Get(got)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(got.Text).To(gomega.Equal(legacy))
+	g.Expect(got.Object.Name).To(gomega.Equal("plain"))
+	// Corrupt (BLOB) value.
+	session = DB.(*Client).pool.Writer()
+	_, err = session.db.Exec(
+		"UPDATE CompressedObject SET text = X'1F8B00'")
+	session.Return()
+	g.Expect(err).To(gomega.BeNil())
+	got = &CompressedObject{ID: 1, Text: "unchanged"}
+	err = DB.Get(got)
+	g.Expect(err).ToNot(gomega.BeNil())
+	g.Expect(got.Text).To(gomega.Equal("unchanged"))
+}
+
 func TestDefinitions(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	list := Definitions{}
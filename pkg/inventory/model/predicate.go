@@ -110,6 +110,16 @@ type Predicate interface {
 	Expr() string
 }
 
+//
+// Predicate matcher.
+// Implemented by predicates that may be evaluated
+// against a model (in memory). Required by watches.
+type Matcher interface {
+	// Match (evaluate) the model and its labels.
+	// The predicate must be built.
+	Match(*Definition, Labels) (bool, error)
+}
+
 //
 // Simple predicate.
 type SimplePredicate struct {
@@ -123,8 +133,18 @@ type SimplePredicate struct {
 
 //
 // Find referenced field.
-func (p *SimplePredicate) match(fields []*Field) (*Field, bool) {
-	return p.field(p.Field, fields)
+func (p *SimplePredicate) match(fields []*Field) (f *Field, err error) {
+	f, found := p.field(p.Field, fields)
+	if !found {
+		err = liberr.Wrap(PredicateRefErr)
+		return
+	}
+	if f.Encrypted() || f.Compressed() {
+		err = liberr.Wrap(PredicateFieldErr, "field", f.Name)
+		return
+	}
+
+	return
 }
 
 //
@@ -143,9 +163,9 @@ func (p *SimplePredicate) field(name string, fields []*Field) (*Field, bool) {
 //
 // Build.
 func (p *SimplePredicate) build(operator string, options *FilterOptions) error {
-	f, found := p.match(options.fields)
-	if !found {
-		return liberr.Wrap(PredicateRefErr)
+	f, err := p.match(options.fields)
+	if err != nil {
+		return err
 	}
 	switch p.Value.(type) {
 	case Field:
@@ -154,6 +174,9 @@ func (p *SimplePredicate) build(operator string, options *FilterOptions) error {
 		if !found {
 			return liberr.Wrap(PredicateRefErr)
 		}
+		if fv.Encrypted() || fv.Compressed() {
+			return liberr.Wrap(PredicateFieldErr, "field", fv.Name)
+		}
 		p.expr = strings.Join(
 			[]string{
 				f.Name,
@@ -176,6 +199,82 @@ func (p *SimplePredicate) build(operator string, options *FilterOptions) error {
 	return nil
 }
 
+//
+// Compare the field value to the predicate value.
+// Returns: -1 (less), 0 (equal), 1 (greater).
+// Bool fields are compared for equality only. Encoded
+// fields are compared as (json) encoded like the SQL.
+func (p *SimplePredicate) compare(md *Definition, value interface{}) (n int, err error) {
+	f, err := p.match(md.Fields)
+	if err != nil {
+		return
+	}
+	if ref, cast := value.(Field); cast {
+		fv, found := p.field(ref.Name, md.Fields)
+		if !found {
+			err = liberr.Wrap(PredicateRefErr)
+			return
+		}
+		if fv.Encoded() {
+			value = fv.pull()
+		} else {
+			value = fv.Value.Interface()
+		}
+	}
+	v, err := f.AsValue(value)
+	if err != nil {
+		return
+	}
+	switch f.Value.Kind() {
+	case reflect.String:
+		n = strings.Compare(f.Value.String(), v.(string))
+	case reflect.Bool:
+		if f.Value.Bool() != v.(bool) {
+			n = 1
+		}
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		a := f.Value.Int()
+		switch b := v.(type) {
+		case float64:
+			n = compareFloat(float64(a), b)
+		default:
+			b64 := reflect.ValueOf(b).Int()
+			switch {
+			case a < b64:
+				n = -1
+			case a > b64:
+				n = 1
+			}
+		}
+	case reflect.Struct,
+		reflect.Slice,
+		reflect.Map:
+		n = strings.Compare(f.pull().(string), v.(string))
+	default:
+		err = liberr.Wrap(FieldTypeErr)
+	}
+
+	return
+}
+
+//
+// Compare float values.
+// Returns: -1 (less), 0 (equal), 1 (greater).
+func compareFloat(a, b float64) (n int) {
+	switch {
+	case a < b:
+		n = -1
+	case a > b:
+		n = 1
+	}
+
+	return
+}
+
 //
 // Equals (=) predicate.
 type EqPredicate struct {
@@ -185,9 +284,9 @@ type EqPredicate struct {
 //
 // Build.
 func (p *EqPredicate) Build(options *FilterOptions) error {
-	f, found := p.match(options.fields)
-	if !found {
-		return liberr.Wrap(PredicateRefErr)
+	f, err := p.match(options.fields)
+	if err != nil {
+		return err
 	}
 	pv := reflect.ValueOf(p.Value)
 	switch pv.Kind() {
@@ -223,6 +322,32 @@ func (p *EqPredicate) Expr() string {
 	return p.expr
 }
 
+//
+// Match the model.
+func (p *EqPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	values := []interface{}{p.Value}
+	pv := reflect.ValueOf(p.Value)
+	if pv.Kind() == reflect.Slice {
+		values = []interface{}{}
+		for i := 0; i < pv.Len(); i++ {
+			values = append(values, pv.Index(i).Interface())
+		}
+	}
+	for _, v := range values {
+		var n int
+		n, err = p.compare(md, v)
+		if err != nil {
+			return
+		}
+		if n == 0 {
+			matched = true
+			break
+		}
+	}
+
+	return
+}
+
 //
 // NotEqual (!=) predicate.
 type NeqPredicate struct {
@@ -241,6 +366,19 @@ func (p *NeqPredicate) Expr() string {
 	return p.expr
 }
 
+//
+// Match the model.
+func (p *NeqPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	n, err := p.compare(md, p.Value)
+	if err != nil {
+		return
+	}
+
+	matched = n != 0
+
+	return
+}
+
 //
 // Greater than (>) predicate.
 type GtPredicate struct {
@@ -250,9 +388,9 @@ type GtPredicate struct {
 //
 // Build.
 func (p *GtPredicate) Build(options *FilterOptions) error {
-	f, found := p.match(options.fields)
-	if !found {
-		return liberr.Wrap(PredicateRefErr)
+	f, err := p.match(options.fields)
+	if err != nil {
+		return err
 	}
 	switch f.Value.Kind() {
 	case reflect.String,
@@ -275,6 +413,19 @@ func (p *GtPredicate) Expr() string {
 	return p.expr
 }
 
+//
+// Match the model.
+func (p *GtPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	n, err := p.compare(md, p.Value)
+	if err != nil {
+		return
+	}
+
+	matched = n > 0
+
+	return
+}
+
 //
 // Less than (<) predicate.
 type LtPredicate struct {
@@ -284,9 +435,9 @@ type LtPredicate struct {
 //
 // Build.
 func (p *LtPredicate) Build(options *FilterOptions) error {
-	f, found := p.match(options.fields)
-	if !found {
-		return liberr.Wrap(PredicateRefErr)
+	f, err := p.match(options.fields)
+	if err != nil {
+		return err
 	}
 	switch f.Value.Kind() {
 	case reflect.String,
@@ -309,6 +460,19 @@ func (p *LtPredicate) Expr() string {
 	return p.expr
 }
 
+//
+// Match the model.
+func (p *LtPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	n, err := p.compare(md, p.Value)
+	if err != nil {
+		return
+	}
+
+	matched = n < 0
+
+	return
+}
+
 //
 // Compound predicate.
 type CompoundPredicate struct {
@@ -348,6 +512,25 @@ func (p *AndPredicate) Expr() string {
 	return expr
 }
 
+//
+// Match the model.
+func (p *AndPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	matched = true
+	for _, p := range p.Predicates {
+		matcher, cast := p.(Matcher)
+		if !cast {
+			err = liberr.Wrap(MatcherErr)
+			return
+		}
+		matched, err = matcher.Match(md, labels)
+		if err != nil || !matched {
+			return
+		}
+	}
+
+	return
+}
+
 //
 // OR predicate.
 type OrPredicate struct {
@@ -380,6 +563,24 @@ func (p *OrPredicate) Expr() string {
 	return expr
 }
 
+//
+// Match the model.
+func (p *OrPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	for _, p := range p.Predicates {
+		matcher, cast := p.(Matcher)
+		if !cast {
+			err = liberr.Wrap(MatcherErr)
+			return
+		}
+		matched, err = matcher.Match(md, labels)
+		if err != nil || matched {
+			return
+		}
+	}
+
+	return
+}
+
 //
 // Label predicate.
 type LabelPredicate struct {
@@ -460,3 +661,18 @@ func (p *LabelPredicate) Len() int {
 func (p *LabelPredicate) Expr() string {
 	return p.expr
 }
+
+//
+// Match the model.
+// All labels must be matched.
+func (p *LabelPredicate) Match(md *Definition, labels Labels) (matched bool, err error) {
+	for k, v := range p.Labels {
+		if lv, found := labels[k]; !found || lv != v {
+			return
+		}
+	}
+
+	matched = true
+
+	return
+}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	liberr "github.com/konveyor/controller/pkg/error"
 	fb "github.com/konveyor/controller/pkg/filebacked"
-	"github.com/mattn/go-sqlite3"
 	"reflect"
 	"strings"
 	"text/template"
@@ -121,6 +120,18 @@ LIMIT {{.Page.Limit}} OFFSET {{.Page.Offset}}
 ;
 `
 
+//
+// Raw SQL used to rewrite stored values.
+var RawSQL = `
+SELECT
+{{ .Pk.Name }}
+{{ range $i,$f := .Fields -}}
+,{{ $f.Name }}
+{{ end -}}
+FROM {{.Table}}
+;
+`
+
 //
 // Errors
 var (
@@ -146,6 +157,20 @@ var (
 	PredicateValueErr = errors.New("predicate value not valid")
 	// Invalid detail level.
 	DetailErr = errors.New("detail level must be <= MaxDetail")
+	// Invalid encrypted field.
+	EncryptedErr = errors.New("encrypted field must be (str, encoded) and not pk, key, unique or indexed")
+	// Invalid compressed field.
+	CompressedErr = errors.New("compressed field must be (str, encoded) and not pk, key, unique or indexed")
+	// Predicate references encrypted or compressed field.
+	PredicateFieldErr = errors.New("predicate not supported for encrypted or compressed field")
+	// Predicate cannot be evaluated (watch).
+	MatcherErr = errors.New("predicate must implement Matcher")
+	// Invalid TTL field.
+	TTLErr = errors.New("ttl field must be (int), not pk and only one per model")
+	// Invalid savepoint name.
+	SavepointNameErr = errors.New("savepoint name must be an identifier")
+	// Savepoint not found.
+	SavepointNotFoundErr = errors.New("savepoint not found")
 )
 
 //
@@ -161,6 +186,8 @@ var (
 type Table struct {
 	// Database connection.
 	DB DBTX
+	// Key provider used to encrypt/decrypt `encrypt` fields.
+	Keys KeyProvider
 }
 
 //
@@ -314,6 +341,9 @@ func (t Table) IndexDDL(md *Definition) (list []string, err error) {
 //
 // Insert the model in the DB.
 // Expects the primary key (PK) to be set.
+// A model conflicting with an existing model is updated.
+// Constraint violations are reported as typed errors:
+// UniqueViolation, FkViolation and NotNullViolation.
 func (t Table) Insert(model interface{}) (err error) {
 	md, err := Inspect(model)
 	if err != nil {
@@ -324,16 +354,20 @@ func (t Table) Insert(model interface{}) (err error) {
 	if err != nil {
 		return
 	}
-	params := t.Params(md)
+	params, err := t.Params(md)
+	if err != nil {
+		return
+	}
 	r, err := t.DB.Exec(stmt, params...)
 	if err != nil {
-		if sql3Err, cast := err.(sqlite3.Error); cast {
-			if sql3Err.Code == sqlite3.ErrConstraint {
-				return t.Update(model)
+		if isUniqueErr(err) {
+			uErr := t.Update(model)
+			if !errors.Is(uErr, NotFound) {
+				return uErr
 			}
 		}
 		err = liberr.Wrap(
-			err,
+			t.translate(md, err),
 			"sql",
 			stmt,
 			"params",
@@ -375,11 +409,15 @@ func (t Table) Update(model interface{}, predicate ...Predicate) (err error) {
 	if err != nil {
 		return
 	}
-	params := append(t.Params(md), options.Params()...)
+	params, err := t.Params(md)
+	if err != nil {
+		return
+	}
+	params = append(params, options.Params()...)
 	r, err := t.DB.Exec(stmt, params...)
 	if err != nil {
 		err = liberr.Wrap(
-			err,
+			t.translate(md, err),
 			"sql",
 			stmt,
 			"params",
@@ -392,7 +430,7 @@ func (t Table) Update(model interface{}, predicate ...Predicate) (err error) {
 		return
 	}
 	if nRows == 0 {
-		err = liberr.Wrap(NotFound)
+		err = liberr.Wrap(notFound(md))
 		return
 	}
 
@@ -421,11 +459,14 @@ func (t Table) Delete(model interface{}) (err error) {
 	if err != nil {
 		return
 	}
-	params := t.Params(md)
+	params, err := t.Params(md)
+	if err != nil {
+		return
+	}
 	r, err := t.DB.Exec(stmt, params...)
 	if err != nil {
 		err = liberr.Wrap(
-			err,
+			t.translate(md, err),
 			"sql",
 			stmt,
 			"params",
@@ -438,7 +479,7 @@ func (t Table) Delete(model interface{}) (err error) {
 		return
 	}
 	if nRows == 0 {
-		err = liberr.Wrap(NotFound)
+		err = liberr.Wrap(notFound(md))
 		return
 	}
 
@@ -466,12 +507,15 @@ func (t Table) Get(model interface{}) (err error) {
 	if err != nil {
 		return
 	}
-	params := t.Params(md)
+	params, err := t.Params(md)
+	if err != nil {
+		return
+	}
 	row := t.DB.QueryRow(stmt, params...)
-	err = t.scan(row, md.Fields)
+	err = t.scan(row, md.Kind, md.Fields)
 	if err != nil {
 		err = liberr.Wrap(
-			err,
+			t.translate(md, err),
 			"sql",
 			stmt,
 			"params",
@@ -540,7 +584,7 @@ func (t Table) List(list interface{}, options ListOptions) (err error) {
 		mInt := mPtr.Interface()
 		mDef, _ := Inspect(mInt)
 		options.fields = mDef.Fields
-		err = t.scan(cursor, options.Fields())
+		err = t.scan(cursor, mDef.Kind, options.Fields())
 		if err != nil {
 			err = liberr.Wrap(err)
 			return
@@ -590,7 +634,7 @@ func (t Table) Find(model interface{}, options ListOptions) (itr fb.Iterator, er
 		mInt := mPtr.Interface()
 		mDef, _ := Inspect(mInt)
 		options.fields = mDef.Fields
-		err = t.scan(cursor, options.Fields())
+		err = t.scan(cursor, mDef.Kind, options.Fields())
 		if err != nil {
 			err = liberr.Wrap(err)
 			return
@@ -612,6 +656,46 @@ func (t Table) Find(model interface{}, options ListOptions) (itr fb.Iterator, er
 	return
 }
 
+//
+// Stream the models in the DB.
+// Qualified by the list options.
+// Returns a (lazy) cursor over the live rows.
+func (t Table) Stream(model interface{}, options ListOptions) (cursor *Cursor, err error) {
+	md, err := Inspect(model)
+	if err != nil {
+		return
+	}
+	stmt, err := t.listSQL(md, &options)
+	if err != nil {
+		return
+	}
+	params := options.Params()
+	rows, err := t.DB.Query(stmt, params...)
+	if err != nil {
+		err = liberr.Wrap(err, "sql", stmt, "params", params)
+		return
+	}
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+	cursor = &Cursor{
+		rows:    rows,
+		keys:    t.Keys,
+		mt:      mt,
+		options: options,
+	}
+
+	log.V(5).Info(
+		"table: stream opened.",
+		"sql",
+		stmt,
+		"params",
+		params)
+
+	return
+}
+
 //
 // Count the models in the DB.
 // Qualified by the model field values and list options.
@@ -650,14 +734,126 @@ func (t Table) Count(model interface{}, predicate Predicate) (count int64, err e
 	return
 }
 
+//
+// Rewrite the stored (raw) values of the specified fields.
+// The stored value of each field is passed to the function
+// which returns the value to be stored and whether it
+// has changed. Used to migrate stored values.  Models are
+// not validated and events are not reported.
+// Returns: the number of models (rows) updated.
+func (t Table) rewrite(md *Definition, fields []*Field, fn RewriteFn) (n int64, err error) {
+	type Row struct {
+		PK     string
+		Values []string
+		Blob   []bool
+	}
+	stmt, err := t.rawSQL(md, fields)
+	if err != nil {
+		return
+	}
+	cursor, err := t.DB.Query(stmt)
+	if err != nil {
+		err = liberr.Wrap(err, "sql", stmt)
+		return
+	}
+	list := fb.NewList()
+	defer list.Close()
+	for cursor.Next() {
+		row := Row{
+			Values: make([]string, len(fields)),
+			Blob:   make([]bool, len(fields)),
+		}
+		values := make([]interface{}, len(fields))
+		ptr := []interface{}{&row.PK}
+		for i := range fields {
+			ptr = append(ptr, &values[i])
+		}
+		err = cursor.Scan(ptr...)
+		if err != nil {
+			_ = cursor.Close()
+			err = liberr.Wrap(err)
+			return
+		}
+		for i, v := range values {
+			switch raw := v.(type) {
+			case []byte:
+				row.Values[i] = string(raw)
+				row.Blob[i] = true
+			case string:
+				row.Values[i] = raw
+			}
+		}
+		list.Append(row)
+	}
+	_ = cursor.Close()
+	pk := md.PkField()
+	stmt, err = t.rewriteSQL(md, fields)
+	if err != nil {
+		return
+	}
+	itr := list.Iter()
+	defer itr.Close()
+	for {
+		row := Row{}
+		if !itr.NextWith(&row) {
+			break
+		}
+		changed := false
+		params := []interface{}{}
+		for i, f := range fields {
+			value, fChanged, fErr := fn(
+				f,
+				row.Values[i],
+				row.Blob[i],
+				aad(md.Kind, row.PK, f.Name))
+			if fErr != nil {
+				err = fErr
+				return
+			}
+			params = append(params, sql.Named(f.Name, value))
+			if fChanged {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		params = append(params, sql.Named(pk.Name, row.PK))
+		_, err = t.DB.Exec(stmt, params...)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"sql",
+				stmt,
+				"params",
+				params)
+			return
+		}
+		n++
+	}
+
+	log.V(5).Info(
+		"table: rewrite succeeded.",
+		"sql",
+		stmt,
+		"updated",
+		n)
+
+	return
+}
+
 //
 // Get the `Fields` referenced as param in SQL.
-func (t Table) Params(md *Definition) (list []interface{}) {
+func (t Table) Params(md *Definition) (list []interface{}, err error) {
 	list = []interface{}{}
 	for _, f := range md.Fields {
 		if f.isParam {
-			p := sql.Named(f.Name, f.Pull())
-			list = append(list, p)
+			var value interface{}
+			value, err = f.Pull(t.Keys, md.aad(f))
+			if err != nil {
+				return
+			}
+			list = append(list, sql.Named(f.Name, value))
 		}
 	}
 
@@ -677,7 +873,7 @@ func (t Table) EnsurePk(md *Definition) {
 	}
 	switch pk.Value.Kind() {
 	case reflect.String:
-		if pk.Pull() != "" {
+		if pk.pull() != "" {
 			return
 		}
 	default:
@@ -689,7 +885,7 @@ func (t Table) EnsurePk(md *Definition) {
 		if matched, _ := withFields[name]; !matched {
 			continue
 		}
-		f.Pull()
+		f.pull()
 		switch f.Value.Kind() {
 		case reflect.String:
 			h.Write([]byte(f.string))
@@ -705,7 +901,7 @@ func (t Table) EnsurePk(md *Definition) {
 		}
 	}
 	pk.string = hex.EncodeToString(h.Sum(nil))
-	pk.Push()
+	pk.push()
 }
 
 //
@@ -930,13 +1126,68 @@ func (t Table) countSQL(md *Definition, options *FilterOptions) (sql string, err
 	return
 }
 
+//
+// Build raw (select) SQL.
+func (t Table) rawSQL(md *Definition, fields []*Field) (sql string, err error) {
+	tpl := template.New("")
+	tpl, err = tpl.Parse(RawSQL)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:  md.Kind,
+			Pk:     md.PkField(),
+			Fields: fields,
+		})
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	sql = bfr.String()
+
+	return
+}
+
+//
+// Build rewrite (update) SQL.
+func (t Table) rewriteSQL(md *Definition, fields []*Field) (sql string, err error) {
+	tpl := template.New("")
+	tpl, err = tpl.Parse(UpdateSQL)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:   md.Kind,
+			Fields:  fields,
+			Options: &FilterOptions{},
+			Pk:      md.PkField(),
+		})
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+
+	sql = bfr.String()
+
+	return
+}
+
 //
 // Scan the fetch row into the model.
 // The model fields are updated.
-func (t Table) scan(row Row, fields []*Field) (err error) {
+func (t Table) scan(row Row, kind string, fields []*Field) (err error) {
 	list := []interface{}{}
 	for _, f := range fields {
-		f.Pull()
+		f.pull()
 		list = append(list, f.Ptr())
 	}
 	err = row.Scan(list...)
@@ -944,8 +1195,17 @@ func (t Table) scan(row Row, fields []*Field) (err error) {
 		err = liberr.Wrap(err)
 		return
 	}
+	pk := ""
 	for _, f := range fields {
-		f.Push()
+		if f.Pk() {
+			pk = f.staged()
+		}
+	}
+	for _, f := range fields {
+		err = f.Push(t.Keys, aad(kind, pk, f.Name))
+		if err != nil {
+			return
+		}
 	}
 
 	return
@@ -1055,3 +1315,10 @@ func (l *FilterOptions) Params() []interface{} {
 //
 // List options
 type ListOptions = FilterOptions
+
+//
+// Rewrite function.
+// Passed the stored value, whether it is stored as BLOB and
+// the AAD used to encrypt the field.
+// Returns the value to be stored and whether it has changed.
+type RewriteFn func(f *Field, stored string, blob bool, aad string) (value interface{}, changed bool, err error)
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/konveyor/controller/pkg/ref"
+	"sync"
+)
+
+//
+// The max depth of (nested) triggers.
+// Triggers may insert, update or delete models that
+// fire other triggers.
+var TriggerDepth = 8
+
+//
+// Errors.
+var (
+	// Trigger depth exceeded.
+	TriggerDepthErr = errors.New("trigger depth exceeded")
+)
+
+//
+// Trigger function.
+// Called within the transaction. An error rolls the
+// transaction back.
+type TriggerFn func(tx *Tx, event Event) error
+
+//
+// Registered trigger.
+type trigger struct {
+	// Actions (created|updated|deleted) mask.
+	actions uint8
+	// Function.
+	fn TriggerFn
+}
+
+//
+// Trigger registry.
+// Triggers are synchronous callbacks by model kind and
+// action called within Tx.Insert(), Tx.Update() and
+// Tx.Delete(), including cascaded deletes.
+type Triggers struct {
+	// Mutex.
+	mutex sync.RWMutex
+	// Triggers by kind.
+	registry map[string][]trigger
+}
+
+//
+// Register a trigger.
+// The `actions` is a mask of: Created|Updated|Deleted.
+func (r *Triggers) Add(model Model, actions uint8, fn TriggerFn) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.registry == nil {
+		r.registry = make(map[string][]trigger)
+	}
+	kind := ref.ToKind(model)
+	r.registry[kind] = append(
+		r.registry[kind],
+		trigger{
+			actions: actions,
+			fn:      fn,
+		})
+}
+
+//
+// Fire triggers matched by the event.
+func (r *Triggers) fire(tx *Tx, event Event) (err error) {
+	r.mutex.RLock()
+	list := r.registry[ref.ToKind(event.Model)]
+	r.mutex.RUnlock()
+	for _, t := range list {
+		if t.actions&event.Action == 0 {
+			continue
+		}
+		err = t.fn(tx, event)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"trigger",
+				event.String())
+			return
+		}
+	}
+
+	return
+}
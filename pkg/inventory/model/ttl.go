@@ -0,0 +1,175 @@
+package model
+
+import (
+	"github.com/go-logr/logr"
+	"sync"
+	"time"
+)
+
+//
+// Reaper defaults.
+const (
+	// The interval at which expired models are reaped.
+	DefaultReapInterval = time.Minute
+	// The max number of expired models deleted per
+	// transaction when reaped.
+	DefaultReapBatch = 100
+)
+
+//
+// Reaper.
+// Periodically deletes models expired based on the
+// `ttl` (expires-at) field. Models are deleted using the
+// normal transaction path so Deleted events are reported.
+type Reaper struct {
+	// DB client.
+	client *Client
+	// Logger.
+	log logr.Logger
+	// Stop requested.
+	stop chan struct{}
+	// Stopped.
+	wg sync.WaitGroup
+}
+
+//
+// Start the reaper.
+// Not started when no models have a `ttl` field.
+func (r *Reaper) Start() {
+	if len(r.definitions()) == 0 {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.log.V(3).Info("reaper: started.")
+		defer r.log.V(3).Info("reaper: stopped.")
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(r.interval()):
+				_, err := r.Reap()
+				if err != nil {
+					r.log.Error(err, "reaper: reap failed.")
+				}
+			}
+		}
+	}()
+}
+
+//
+// Stop the reaper.
+// Blocks until the reaper has stopped.
+func (r *Reaper) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	r.wg.Wait()
+	r.stop = nil
+}
+
+//
+// Reap (delete) expired models.
+// Returns: the number of models deleted.
+func (r *Reaper) Reap() (n int, err error) {
+	mark := time.Now()
+	batch := r.batch()
+	for _, md := range r.definitions() {
+		for {
+			var deleted int
+			deleted, err = r.reap(md, mark.Unix(), batch)
+			if err != nil {
+				return
+			}
+			n += deleted
+			if deleted < batch {
+				break
+			}
+		}
+	}
+	if n > 0 {
+		r.log.V(3).Info(
+			"reaper: reaped.",
+			"deleted",
+			n,
+			"duration",
+			time.Since(mark))
+	}
+
+	return
+}
+
+//
+// Reap (delete) a batch of expired models.
+func (r *Reaper) reap(md *Definition, now int64, batch int) (n int, err error) {
+	ttl := md.TTLField()
+	err = r.client.With(func(tx *Tx) (err error) {
+		itr, err := tx.Find(
+			md.NewModel(),
+			ListOptions{
+				Predicate: And(
+					Gt(ttl.Name, 0),
+					Lt(ttl.Name, now+1)),
+				Page: &Page{
+					Limit: batch,
+				},
+			})
+		if err != nil {
+			return
+		}
+		defer itr.Close()
+		for {
+			object, hasNext := itr.Next()
+			if !hasNext {
+				break
+			}
+			err = tx.Delete(object.(Model))
+			if err != nil {
+				return
+			}
+			n++
+		}
+		return
+	})
+
+	return
+}
+
+//
+// Definitions with a `ttl` field.
+func (r *Reaper) definitions() (list Definitions) {
+	for _, md := range r.client.dm.Definitions() {
+		if md.TTLField() != nil {
+			list = append(list, md)
+		}
+	}
+
+	return
+}
+
+//
+// The reap interval.
+// Settings.ReapInterval <= 0 = DefaultReapInterval.
+func (r *Reaper) interval() (d time.Duration) {
+	d = r.client.settings.ReapInterval
+	if d <= 0 {
+		d = DefaultReapInterval
+	}
+
+	return
+}
+
+//
+// The reap batch size.
+// Settings.ReapBatch <= 0 = DefaultReapBatch.
+func (r *Reaper) batch() (n int) {
+	n = r.client.settings.ReapBatch
+	if n <= 0 {
+		n = DefaultReapBatch
+	}
+
+	return
+}
@@ -14,6 +14,7 @@ import (
 	liburl "net/url"
 	"reflect"
 	"runtime"
+	"strconv"
 	"time"
 )
 
@@ -24,10 +25,28 @@ const (
 	WatchHeader = "X-Watch"
 	// Options.
 	WatchSnapshot = "snapshot"
+	WatchResume   = "resume:"
+	WatchInclude  = "include:"
+	WatchExclude  = "exclude:"
+	WatchDiff     = "diff"
 )
 
 type WatchOptions = libmodel.WatchOptions
 
+//
+// Watch errors reported by the peer (by description)
+// and delivered to EventHandler.Error() as the
+// (comparable) error.
+var WatchErrors = []error{
+	libmodel.ResumeTooOldErr,
+	libmodel.BackpressureErr,
+	libmodel.HandlerPanicErr,
+	libmodel.WatchKindErr,
+	libmodel.PredicateRefErr,
+	libmodel.PredicateTypeErr,
+	libmodel.PredicateValueErr,
+}
+
 //
 // Event handler
 type EventHandler interface {
@@ -252,6 +271,20 @@ func (r *Client) Watch(url string, resource interface{}, h EventHandler) (status
 	if h.Options().Snapshot {
 		options = []string{WatchSnapshot}
 	}
+	if id := h.Options().ResumeFrom; id > 0 {
+		options = append(
+			options,
+			WatchResume+strconv.FormatUint(id, 10))
+	}
+	if h.Options().Diff {
+		options = append(options, WatchDiff)
+	}
+	for _, label := range h.Options().IncludeLabels {
+		options = append(options, WatchInclude+label)
+	}
+	for _, label := range h.Options().ExcludeLabels {
+		options = append(options, WatchExclude+label)
+	}
 	header := http.Header{
 		WatchHeader: options,
 	}
@@ -422,9 +455,23 @@ func (r *WatchReader) start() {
 				r.resetLog()
 				r.handler.Started(r.id)
 			case libmodel.Parity:
-				r.handler.Parity()
+				if handler, cast := r.handler.(libmodel.ParityHandler); cast {
+					handler.ParityAt(event.ID)
+				} else {
+					r.handler.Parity()
+				}
 			case libmodel.Error:
-				r.handler.Error(&Watch{reader: r}, nil)
+				var err error
+				if event.Error != "" {
+					err = liberr.New(event.Error)
+					for _, known := range WatchErrors {
+						if event.Error == known.Error() {
+							err = known
+							break
+						}
+					}
+				}
+				r.handler.Error(&Watch{reader: r}, err)
 			case libmodel.End:
 				return
 			case libmodel.Created:
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
@@ -12,6 +13,7 @@ import (
 	"github.com/konveyor/controller/pkg/ref"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -113,6 +115,10 @@ type Event struct {
 	Resource interface{}
 	// Updated resource.
 	Updated interface{}
+	// Field changes (updated).
+	Changes []model.Change `json:",omitempty"`
+	// Error description.
+	Error string `json:",omitempty"`
 }
 
 //
@@ -214,8 +220,19 @@ func (r *WatchWriter) Started(watchID uint64) {
 //
 // Watch has parity.
 func (r *WatchWriter) Parity() {
-	r.log.V(3).Info("event: parity.")
+	r.ParityAt(0)
+}
+
+//
+// Watch has parity.
+// The event ID is the snapshot boundary.
+func (r *WatchWriter) ParityAt(id uint64) {
+	r.log.V(3).Info(
+		"event: parity.",
+		"boundary",
+		id)
 	r.send(model.Event{
+		ID:     id,
 		Action: model.Parity,
 	})
 }
@@ -257,9 +274,11 @@ func (r *WatchWriter) Error(err error) {
 		"event: error",
 		"error",
 		err.Error())
-	r.send(model.Event{
-		Action: model.Error,
-	})
+	r.write(
+		Event{
+			Action: model.Error,
+			Error:  err.Error(),
+		})
 }
 
 //
@@ -275,7 +294,9 @@ func (r *WatchWriter) End() {
 }
 
 //
-// Write event to the socket.
+// Build the (web) event and write it to the socket.
+// Changes are built from the resources so that only
+// the resource (rather than model) fields are exposed.
 func (r *WatchWriter) send(e model.Event) {
 	if r.done {
 		return
@@ -291,6 +312,24 @@ func (r *WatchWriter) send(e model.Event) {
 	if e.Updated != nil {
 		event.Updated = r.builder(e.Updated)
 	}
+	if r.options.Diff && event.Resource != nil && event.Updated != nil {
+		changes, err := model.DiffJSON(event.Resource, event.Updated)
+		if err == nil {
+			event.Changes = changes
+		} else {
+			r.log.V(4).Error(err, "resource diff failed.")
+		}
+	}
+
+	r.write(event)
+}
+
+//
+// Write event to the socket.
+func (r *WatchWriter) write(event Event) {
+	if r.done {
+		return
+	}
 	err := r.webSocket.WriteJSON(event)
 	if err != nil {
 		r.log.V(4).Error(err, "websocket send failed.")
@@ -319,9 +358,25 @@ func (h *Watched) Prepare(ctx *gin.Context) int {
 	header, found := ctx.Request.Header[WatchHeader]
 	h.WatchRequest = found
 	for _, option := range header {
-		switch option {
-		case WatchSnapshot:
+		switch {
+		case option == WatchSnapshot:
 			h.options.Snapshot = true
+		case option == WatchDiff:
+			h.options.Diff = true
+		case strings.HasPrefix(option, WatchResume):
+			id, err := strconv.ParseUint(option[len(WatchResume):], 10, 64)
+			if err != nil {
+				return http.StatusBadRequest
+			}
+			h.options.ResumeFrom = id
+		case strings.HasPrefix(option, WatchInclude):
+			h.options.IncludeLabels = append(
+				h.options.IncludeLabels,
+				option[len(WatchInclude):])
+		case strings.HasPrefix(option, WatchExclude):
+			h.options.ExcludeLabels = append(
+				h.options.ExcludeLabels,
+				option[len(WatchExclude):])
 		}
 	}
 
@@ -360,7 +415,11 @@ func (r *Watched) Watch(
 	}
 	watch, err := db.Watch(m, writer)
 	if err != nil {
-		_ = socket.Close()
+		writer.Error(err)
+		writer.End()
+		if errors.Is(err, model.ResumeTooOldErr) {
+			err = nil
+		}
 		return
 	}
 	writer.log = logging.WithName(name).WithValues(